@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/bot"
+)
+
+func testPrompt(ctx context.Context, args []string) error {
+	var configPath, persona, imagePath string
+	userContext := ai.SampleUserContext()
+	flags := newFlagSet("ai test-prompt", &configPath)
+	flags.StringVar(&persona, "persona", "", "AI persona to answer as, the default one if empty")
+	flags.StringVar(&userContext.Username, "username", userContext.Username, "Username of the message sender")
+	flags.StringVar(&userContext.FirstName, "first-name", userContext.FirstName, "First name of the message sender")
+	flags.StringVar(&userContext.LastName, "last-name", userContext.LastName, "Last name of the message sender")
+	flags.StringVar(&imagePath, "image", "", "Path of the image attached to the message")
+	_ = flags.Parse(args)
+
+	text := strings.Join(flags.Args(), " ")
+	if text == "" {
+		return errors.New("message text must be passed as an argument")
+	}
+
+	config, err := loadAIConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if !config.Enabled() {
+		return errors.New("AI API key is not set")
+	}
+
+	if persona == "" {
+		persona = config.DefaultPersona
+	}
+	if persona != ai.RandomPersona {
+		systemPrompt, err := ai.RenderSystemPrompt(config, persona, userContext)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("system prompt:\n\n%s\n\n", systemPrompt)
+	}
+
+	aiHandler, err := ai.NewAI(config)
+	if err != nil {
+		return fmt.Errorf("create ai handler: %w", err)
+	}
+	defer func() { _ = aiHandler.Close() }()
+
+	var images []ai.Image
+	if imagePath != "" {
+		data, err := os.ReadFile(imagePath)
+		if err != nil {
+			return fmt.Errorf("read image: %w", err)
+		}
+		images = append(images, ai.Image{MediaType: http.DetectContentType(data), Data: data})
+	}
+
+	reply, err := aiHandler.GenerateReply(ctx, persona, text, userContext, nil, images)
+	if err != nil {
+		return fmt.Errorf("generate response: %w", err)
+	}
+	fmt.Printf("reply:\n\n%s\n", reply)
+	return nil
+}
+
+// loadAIConfig loads the config and validates only its ai section, so that prompts can be
+// tried without the bot token and the rest of bot settings.
+func loadAIConfig(configPath string) (*ai.Config, error) {
+	config, err := bot.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	if config.AI == nil {
+		return nil, errors.New("validate config: ai section is required")
+	}
+	if err := config.AI.Validate(); err != nil {
+		return nil, fmt.Errorf("validate ai config: %w", err)
+	}
+	return config.AI, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func broadcastMessage(ctx context.Context, args []string) error {
+	var configPath, filePath string
+	var opts runOptions
+	flags := newFlagSet("broadcast", &configPath)
+	flags.StringVar(&filePath, "file", "", "Path to file with message text")
+	flags.StringVar(&opts.dbPath, "db", "", "Path to sqlite database, overrides sqlite_path from config")
+	_ = flags.Parse(args)
+
+	if filePath == "" {
+		return errors.New("-file must be set")
+	}
+	text, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("read message: %w", err)
+	}
+	if strings.TrimSpace(string(text)) == "" {
+		return fmt.Errorf("message in %s is empty", filePath)
+	}
+
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	// Chats are read from the database, so broadcasting from an empty in-memory one
+	// would report success without sending anything.
+	if _, err := storagePath(config, opts.dbPath); err != nil {
+		return err
+	}
+	bot, err := createBot(config, configPath, opts)
+	if err != nil {
+		return fmt.Errorf("create bot: %w", err)
+	}
+
+	result, err := bot.Broadcast(ctx, string(text))
+	if err != nil {
+		return fmt.Errorf("broadcast: %w", err)
+	}
+	fmt.Printf(
+		"Finished broadcasting to %d chats: %d success, %d failure\n",
+		result.Total, result.Success, result.Failure,
+	)
+	return nil
+}
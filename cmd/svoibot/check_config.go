@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/bot"
+)
+
+func checkConfig(_ context.Context, args []string) error {
+	var configPath string
+	flags := newFlagSet("check-config", &configPath)
+	_ = flags.Parse(args)
+
+	config, err := bot.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "config %s is invalid:\n", configPath)
+		for _, err := range unwrapJoined(err) {
+			fmt.Fprintf(os.Stderr, "  - %s\n", err)
+		}
+		return errors.New("config is invalid")
+	}
+	fmt.Printf("config %s is valid\n", configPath)
+
+	if !config.AI.Enabled() {
+		fmt.Println("AI responses are disabled, skipping system prompt rendering")
+		return nil
+	}
+
+	userContext := ai.SampleUserContext()
+	for _, persona := range config.AI.PersonaNames() {
+		systemPrompt, err := ai.RenderSystemPrompt(config.AI, persona, userContext)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nsystem prompt of persona %q rendered for %+v:\n\n%s\n", persona, userContext, systemPrompt)
+	}
+	return nil
+}
+
+// unwrapJoined flattens errors produced by errors.Join, so that each problem is printed on its own line.
+func unwrapJoined(err error) (errs []error) {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, unwrapJoined(err)...)
+	}
+	return errs
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func vacuumDB(ctx context.Context, args []string) error {
+	var configPath, dbPath string
+	flags := newFlagSet("db vacuum", &configPath)
+	flags.StringVar(&dbPath, "db", "", "Path to sqlite database, overrides sqlite_path from config")
+	_ = flags.Parse(args)
+
+	dbconn, err := openDB(ctx, configPath, dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dbconn.Close() }()
+
+	if err := dbconn.Vacuum(ctx); err != nil {
+		return err
+	}
+	fmt.Println("database vacuumed")
+	return nil
+}
+
+func backupDB(ctx context.Context, args []string) error {
+	var configPath, dbPath, outputPath string
+	flags := newFlagSet("db backup", &configPath)
+	flags.StringVar(&dbPath, "db", "", "Path to sqlite database, overrides sqlite_path from config")
+	flags.StringVar(&outputPath, "output", "", "Path to write backup to, must not exist")
+	_ = flags.Parse(args)
+
+	if outputPath == "" {
+		return errors.New("-output must be set")
+	}
+
+	dbconn, err := openDB(ctx, configPath, dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dbconn.Close() }()
+
+	if err := dbconn.Backup(ctx, outputPath); err != nil {
+		return err
+	}
+	fmt.Printf("database backed up to %s\n", outputPath)
+	return nil
+}
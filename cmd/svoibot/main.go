@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	_ "time/tzdata" // the image is built from scratch and has no time zone database
 
 	"github.com/LeKSuS-04/svoi-bot/internal/bot"
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
 	"github.com/LeKSuS-04/svoi-bot/internal/logging"
 )
 
@@ -17,45 +22,169 @@ const (
 	SqliteDBPathEnvKey = "SQLITE_PATH"
 )
 
+type command struct {
+	name        string
+	description string
+	run         func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{
+		name:        "run",
+		description: "Run the bot (default)",
+		run:         runBot,
+	},
+	{
+		name:        "check-config",
+		description: "Validate config and render system prompt with sample data",
+		run:         checkConfig,
+	},
+	{
+		name:        "stats export",
+		description: "Export collected stats to CSV or JSON",
+		run:         exportStats,
+	},
+	{
+		name:        "stats import",
+		description: "Merge stats exported from another deployment",
+		run:         importStats,
+	},
+	{
+		name:        "db vacuum",
+		description: "Rebuild the database file, reclaiming unused space",
+		run:         vacuumDB,
+	},
+	{
+		name:        "db backup",
+		description: "Write a consistent copy of the database to a file",
+		run:         backupDB,
+	},
+	{
+		name:        "broadcast",
+		description: "Send a message from a file to every known chat",
+		run:         broadcastMessage,
+	},
+	{
+		name:        "ai test-prompt",
+		description: "Render system prompt, send text to AI provider and print the reply",
+		run:         testPrompt,
+	},
+}
+
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancel()
 
-	var configPath string
-	flag.StringVar(&configPath, "config", "", "Path to config file")
-	flag.Parse()
-
-	log := logging.New("setup")
+	cmd, args, ok := findCommand(os.Args[1:])
+	if !ok {
+		printUsage()
+		os.Exit(2)
+	}
 
-	bot, err := createBot(configPath)
-	if err != nil {
-		log.ErrorContext(ctx, "failed to create bot", "error", err)
+	if err := cmd.run(ctx, args); err != nil {
+		log := logging.New("cli")
+		log.ErrorContext(ctx, "command failed", "command", cmd.name, "error", err)
+		cancel()
 		os.Exit(1)
 	}
+}
+
+// findCommand matches the longest command name that args start with.
+// Running without a command starts the bot to stay compatible with
+// existing deployments that only pass -config.
+func findCommand(args []string) (_ command, rest []string, ok bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args, true
+	}
 
-	err = bot.Run(ctx)
-	if err != nil {
-		log.ErrorContext(ctx, "failed to run bot", "error", err)
-		os.Exit(1)
+	for _, cmd := range commands {
+		parts := strings.Fields(cmd.name)
+		if len(args) >= len(parts) && slices.Equal(args[:len(parts)], parts) {
+			return cmd, args[len(parts):], true
+		}
 	}
+	return command{}, nil, false
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' to see command flags.\n", os.Args[0])
+}
+
+func newFlagSet(name string, configPath *string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(configPath, "config", "", "Path to config file")
+	return flags
 }
 
-func createBot(configPath string) (*bot.Bot, error) {
+// loadConfig loads and validates config shared by all commands.
+func loadConfig(configPath string) (*bot.Config, error) {
 	config, err := bot.LoadConfig(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("load config: %w", err)
 	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config: %w", err)
+	}
+	return config, nil
+}
+
+// openStorage opens storage selected in config. If dbPath is set, sqlite database
+// at that path is opened instead. Config is not validated, as only storage settings
+// are needed from it.
+func openStorage(ctx context.Context, configPath, dbPath string) (db.Storage, error) {
+	config := &bot.Config{}
+	if configPath != "" {
+		var err error
+		config, err = bot.LoadConfig(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("load config: %w", err)
+		}
+	} else if dbPath == "" {
+		return nil, errors.New("either -config or -db must be set")
+	}
+
+	if dbPath != "" {
+		config.StorageDriver = bot.StorageSqlite
+	}
+	dbPath, err := storagePath(config, dbPath)
+	if err != nil {
+		return nil, err
+	}
 
-	var opts []bot.Option
-	opts = append(opts, bot.WithWorkerCount(16))
+	storage, err := bot.OpenStorage(ctx, config, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	return storage, nil
+}
 
-	if config.SqlitePath != "" {
-		opts = append(opts, bot.WithDBPath(config.SqlitePath))
+// storagePath returns the sqlite database path set by -db or in config. Commands working
+// with stored data fail without it, as an in-memory database would silently be empty.
+func storagePath(config *bot.Config, dbPath string) (string, error) {
+	if dbPath == "" {
+		dbPath = config.SqlitePath
 	}
+	if config.StorageDriver == bot.StorageSqlite && dbPath == "" {
+		return "", errors.New("database path is not set")
+	}
+	return dbPath, nil
+}
 
-	bot, err := bot.NewBot(config, opts...)
+// openDB opens sqlite database for commands that work with the database file directly.
+func openDB(ctx context.Context, configPath, dbPath string) (*db.DB, error) {
+	storage, err := openStorage(ctx, configPath, dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("create new bot: %w", err)
+		return nil, err
+	}
+
+	sqlite, ok := db.AsSqlite(storage)
+	if !ok {
+		_ = storage.Close()
+		return nil, errors.New("command is only supported for sqlite storage")
 	}
-	return bot, nil
+	return sqlite, nil
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/bot"
+)
+
+type runOptions struct {
+	workerCount          int
+	cacheDuration        time.Duration
+	cacheCleanupInterval time.Duration
+	dbPath               string
+}
+
+func runBot(ctx context.Context, args []string) error {
+	var configPath string
+	var opts runOptions
+	flags := newFlagSet("run", &configPath)
+	flags.IntVar(&opts.workerCount, "workers", 16, "Number of workers handling updates")
+	flags.DurationVar(&opts.cacheDuration, "cache-duration", time.Hour, "Default expiration of cached entries")
+	flags.DurationVar(&opts.cacheCleanupInterval, "cache-cleanup-interval", 5*time.Minute, "Interval between removals of expired cache entries")
+	flags.StringVar(&opts.dbPath, "db", "", "Path to sqlite database, overrides sqlite_path from config")
+	_ = flags.Parse(args)
+
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	bot, err := createBot(config, configPath, opts)
+	if err != nil {
+		return fmt.Errorf("create bot: %w", err)
+	}
+
+	if err := bot.Run(ctx); err != nil {
+		return fmt.Errorf("run bot: %w", err)
+	}
+	return nil
+}
+
+func createBot(config *bot.Config, configPath string, runOpts runOptions) (*bot.Bot, error) {
+	var opts []bot.Option
+	opts = append(opts, bot.WithConfigPath(configPath))
+	opts = append(opts, bot.WithWorkerCount(runOpts.workerCount))
+	opts = append(opts, bot.WithCacheDuration(runOpts.cacheDuration))
+	opts = append(opts, bot.WithCacheCleanupInterval(runOpts.cacheCleanupInterval))
+
+	switch {
+	case runOpts.dbPath != "":
+		opts = append(opts, bot.WithDBPath(runOpts.dbPath))
+	case config.SqlitePath != "":
+		opts = append(opts, bot.WithDBPath(config.SqlitePath))
+	}
+
+	bot, err := bot.NewBot(config, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create new bot: %w", err)
+	}
+	return bot, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+)
+
+func exportStats(ctx context.Context, args []string) error {
+	var configPath, dbPath, format, outputPath string
+	var chatID int
+	flags := newFlagSet("stats export", &configPath)
+	flags.StringVar(&dbPath, "db", "", "Path to sqlite database, overrides sqlite_path from config")
+	flags.StringVar(&format, "format", string(db.FormatJSON), "Export format: csv or json")
+	flags.IntVar(&chatID, "chat", 0, "Only export stats of the chat with this ID")
+	flags.StringVar(&outputPath, "output", "", "Path to write export to, stdout if not set")
+	_ = flags.Parse(args)
+
+	exportFormat, err := db.ParseExportFormat(format)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage(ctx, configPath, dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = storage.Close() }()
+
+	stats, err := storage.ExportStats(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("create output file: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		output = file
+	}
+
+	if err := db.WriteStats(output, exportFormat, stats); err != nil {
+		return fmt.Errorf("write stats: %w", err)
+	}
+	if outputPath != "" {
+		fmt.Fprintf(os.Stderr, "exported %d records to %s\n", len(stats), outputPath)
+	}
+	return nil
+}
+
+func importStats(ctx context.Context, args []string) error {
+	var configPath, dbPath, format, inputPath, strategy string
+	flags := newFlagSet("stats import", &configPath)
+	flags.StringVar(&dbPath, "db", "", "Path to sqlite database, overrides sqlite_path from config")
+	flags.StringVar(&format, "format", "", "Import format: csv or json, detected from file extension if not set")
+	flags.StringVar(&inputPath, "file", "", "Path to exported stats")
+	flags.StringVar(&strategy, "strategy", string(db.MergeSum), "How to merge with existing stats: sum, replace or max")
+	_ = flags.Parse(args)
+
+	if inputPath == "" {
+		return errors.New("-file must be set")
+	}
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(inputPath), ".")
+	}
+	importFormat, err := db.ParseExportFormat(format)
+	if err != nil {
+		return err
+	}
+	mergeStrategy, err := db.ParseMergeStrategy(strategy)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("open input file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	stats, err := db.ReadStats(file, importFormat)
+	if err != nil {
+		return fmt.Errorf("read stats: %w", err)
+	}
+
+	storage, err := openStorage(ctx, configPath, dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = storage.Close() }()
+
+	if err := storage.ImportStats(ctx, stats, mergeStrategy); err != nil {
+		return fmt.Errorf("import stats: %w", err)
+	}
+	fmt.Printf("imported %d records using %q strategy\n", len(stats), mergeStrategy)
+	return nil
+}
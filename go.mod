@@ -3,6 +3,8 @@ module github.com/LeKSuS-04/svoi-bot
 go 1.24.4
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/lib/pq v1.10.9
 	github.com/mymmrac/telego v0.30.2
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/phsym/console-slog v0.3.1
@@ -4,31 +4,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/LeKSuS-04/svoi-bot/internal/logging"
 )
 
-type Config struct {
-	BaseURL             string        `yaml:"base_url"`
-	APIKey              string        `env:"AI_API_KEY"`
-	Model               string        `yaml:"model"`
-	FallbackModels      []string      `yaml:"fallback_models"`
-	ResponseResetPeriod time.Duration `yaml:"reset_period"`
-	SystemPrompt        string        `yaml:"system_prompt"`
-}
-
 type AI struct {
-	model        string
-	cfg          *Config
-	log          *slog.Logger
-	systemPrompt *template.Template
+	mu        sync.RWMutex
+	cfg       *Config
+	log       *slog.Logger
+	personas  map[string]*persona
+	moderator *moderator
+	client    *http.Client
+	cache     *responseCache
 }
 
 type UserContext struct {
@@ -38,24 +35,177 @@ type UserContext struct {
 }
 
 func NewAI(config *Config) (*AI, error) {
-	systemPrompt, err := template.New("system_prompt").Parse(config.SystemPrompt)
+	a := &AI{
+		log: logging.New("ai"),
+	}
+	if err := a.UpdateConfig(config); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// UpdateConfig re-parses system prompts of personas and atomically replaces the configuration
+// used for subsequent generations. Generations that are already in flight keep using
+// the previous configuration.
+func (a *AI) UpdateConfig(config *Config) error {
+	personas, err := newPersonas(config)
+	if err != nil {
+		return err
+	}
+	moderator, err := newModerator(config.Moderation)
+	if err != nil {
+		return err
+	}
+	client, err := newHTTPClient(config.HTTP)
+	if err != nil {
+		return fmt.Errorf("new http client: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.updateCache(config.Cache); err != nil {
+		return err
+	}
+	if a.client != nil {
+		a.client.CloseIdleConnections()
+	}
+	a.cfg = config
+	a.personas = personas
+	a.moderator = moderator
+	a.client = client
+	return nil
+}
+
+// updateCache replaces the response cache if its config changed, so that cached responses
+// survive reloads of unrelated config. Must be called with mu held.
+func (a *AI) updateCache(config CacheConfig) error {
+	if a.cfg != nil && a.cfg.Cache == config {
+		return nil
+	}
+
+	var cache *responseCache
+	if config.Enabled {
+		var err error
+		cache, err = newResponseCache(config, a.log)
+		if err != nil {
+			return fmt.Errorf("new response cache: %w", err)
+		}
+	}
+	if a.cache != nil {
+		if err := a.cache.Close(); err != nil {
+			a.log.Error("failed to close response cache", "error", err)
+		}
+	}
+	a.cache = cache
+	return nil
+}
+
+// Close releases resources held by the AI handler.
+func (a *AI) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cache == nil {
+		return nil
+	}
+	return a.cache.Close()
+}
+
+func parseSystemPrompt(systemPrompt string) (*template.Template, error) {
+	t, err := template.New("system_prompt").Option("missingkey=error").Parse(systemPrompt)
 	if err != nil {
 		return nil, fmt.Errorf("parse system prompt: %w", err)
 	}
+	return t, nil
+}
+
+// RenderSystemPrompt renders the system prompt of the persona for the given user, the way it is sent to the model.
+func RenderSystemPrompt(config *Config, personaName string, userContext UserContext) (string, error) {
+	i := slices.IndexFunc(config.AllPersonas(), func(persona PersonaConfig) bool {
+		return persona.Name == personaName
+	})
+	if i < 0 {
+		return "", fmt.Errorf("unknown persona %q", personaName)
+	}
 
-	return &AI{
-		model:        config.Model,
-		cfg:          config,
-		log:          logging.New("ai"),
-		systemPrompt: systemPrompt,
-	}, nil
+	systemPrompt, err := parseSystemPrompt(config.AllPersonas()[i].SystemPrompt)
+	if err != nil {
+		return "", err
+	}
+	return renderSystemPrompt(systemPrompt, userContext.sanitized(config.Input.MaxNameLength))
+}
+
+// renderSystemPrompt renders the system prompt for the sanitized user context, framing
+// the names of the user.
+func renderSystemPrompt(systemPrompt *template.Template, userContext UserContext) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := systemPrompt.Execute(buf, userContext.framed()); err != nil {
+		return "", fmt.Errorf("render system prompt: %w", err)
+	}
+	return strings.TrimSpace(buf.String()) + "\n\n" + framingInstructions, nil
 }
 
-func (a *AI) GeneratePatrioticResponse(ctx context.Context, prompt string, userContext UserContext) (response string, err error) {
+func (a *AI) snapshot() (cfg *Config, personas map[string]*persona, moderator *moderator) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.cfg, a.personas, a.moderator
+}
+
+func (a *AI) httpClient() *http.Client {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.client
+}
+
+func (a *AI) responseCache() *responseCache {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.cache
+}
+
+// Turn is an earlier message of the conversation a prompt continues.
+type Turn struct {
+	// FromBot marks messages of the bot itself, which are passed to the model as its own answers.
+	FromBot bool
+	User    UserContext
+	Text    string
+}
+
+// GeneratePatrioticResponse answers the prompt as the named persona. Empty and unknown names
+// stand for the default persona, and RandomPersona picks a random one.
+func (a *AI) GeneratePatrioticResponse(ctx context.Context, personaName, prompt string, userContext UserContext) (string, error) {
+	return a.GenerateReply(ctx, personaName, prompt, userContext, nil, nil)
+}
+
+// GenerateReply answers the prompt as the named persona, continuing the conversation made
+// of history turns, from the oldest one. Images are attached to the prompt if the model
+// accepts them. Replies within a conversation and replies to images are never cached.
+func (a *AI) GenerateReply(ctx context.Context, personaName, prompt string, userContext UserContext, history []Turn, images []Image) (response string, err error) {
+	cfg, personas, moderator := a.snapshot()
+	persona := a.pickPersona(cfg, personas, personaName)
+
+	// Cached responses were moderated when generated, and are shared between users, so only
+	// responses that don't mention or name their sender are cached.
+	budget := a.responseCache()
+	cache := budget
+	if len(history) > 0 || len(images) > 0 {
+		cache = nil
+	}
+	if cache != nil {
+		if response, similar, ok := cache.get(persona.Name, prompt); ok {
+			match := exactMatch
+			if similar {
+				match = similarMatch
+			}
+			cachedResponses.WithLabelValues(match).Inc()
+			a.log.DebugContext(ctx, "using cached ai response", "persona", persona.Name, "match", match, "response", response)
+			return response, nil
+		}
+	}
+
 	start := time.Now()
 	defer func() {
 		if err != nil {
-			failedGenerations.Inc()
+			failedGenerations.WithLabelValues(failureReason(err)).Inc()
 		} else {
 			successfulGenerations.Inc()
 		}
@@ -63,79 +213,148 @@ func (a *AI) GeneratePatrioticResponse(ctx context.Context, prompt string, userC
 		generationDurationSeconds.Observe(duration)
 	}()
 
-	systemPromptBuf := bytes.NewBuffer(nil)
-	err = a.systemPrompt.Execute(systemPromptBuf, userContext)
+	// Names and the message are controlled by users, so they are sanitized and framed
+	// to keep users from steering the bot with them.
+	userContext = userContext.sanitized(cfg.Input.MaxNameLength)
+	renderedPrompt, err := renderSystemPrompt(persona.systemPrompt, userContext)
 	if err != nil {
-		return "", fmt.Errorf("render system prompt: %w", err)
+		return "", err
 	}
-	a.log.DebugContext(ctx, "rendered system prompt", "prompt", systemPromptBuf.String(), "userContext", userContext)
+	a.log.DebugContext(ctx, "rendered system prompt", "persona", persona.Name, "prompt", renderedPrompt, "userContext", userContext)
 
-	reqModel := OpenrouterRequest{
-		Model:  a.model,
-		Models: a.cfg.FallbackModels,
-		Messages: []Message{
-			{Role: "system", Content: systemPromptBuf.String()},
-			{Role: "user", Content: prompt},
-		},
+	messages := []Message{{Role: "system", Content: renderedPrompt}}
+	for _, example := range persona.Examples {
+		messages = append(messages,
+			Message{Role: "user", Content: frameMessage(UserContext{}, example.User)},
+			Message{Role: "assistant", Content: example.Assistant},
+		)
+	}
+	participants := []string{userContext.Username}
+	for _, turn := range history {
+		if turn.FromBot {
+			messages = append(messages, Message{Role: "assistant", Content: turn.Text})
+			continue
+		}
+		user := turn.User.sanitized(cfg.Input.MaxNameLength)
+		participants = append(participants, user.Username)
+		messages = append(messages, Message{
+			Role:    "user",
+			Content: frameMessage(user, sanitize(turn.Text, cfg.Input.MaxMessageLength, true)),
+		})
+	}
+	userMessage := Message{
+		Role:    "user",
+		Content: frameMessage(userContext, sanitize(prompt, cfg.Input.MaxMessageLength, true)),
+	}
+	fallbackModels := cfg.FallbackModels
+	if len(images) > 0 {
+		if cfg.Images.accepts(persona.Model) {
+			userMessage.Parts = contentParts(cfg.Images.Format, userMessage.Content, images)
+		} else {
+			a.log.DebugContext(ctx, "model does not accept images, dropping them", "model", persona.Model)
+		}
+	}
+	if len(userMessage.Parts) > 0 {
+		// Falling back to a model that doesn't accept images would fail the request.
+		fallbackModels = slices.DeleteFunc(slices.Clone(fallbackModels), func(model string) bool {
+			return !cfg.Images.accepts(model)
+		})
+	}
+	messages = append(messages, userMessage)
+
+	message, err := a.complete(ctx, cfg, persona.Name, OpenrouterRequest{
+		Model:            persona.Model,
+		Models:           fallbackModels,
+		Messages:         messages,
+		GenerationConfig: persona.GenerationConfig,
+	})
+	if err != nil {
+		return "", err
+	}
+	a.log.DebugContext(ctx, "generated ai response", "response", message)
+	// Every generation is paid for, including ones that are rejected or not cacheable.
+	if budget != nil {
+		budget.countGeneration(time.Now())
 	}
 
+	moderated, err := a.moderate(ctx, cfg, persona.Name, moderator, message, renderedPrompt, participants)
+	if err != nil {
+		a.log.WarnContext(ctx, "ai response rejected", "response", message, "error", err)
+		return "", err
+	}
+
+	// Responses addressing the sender would address another user on a cache hit.
+	if cache != nil && !addressesUser(moderated, userContext) {
+		cache.put(ctx, persona.Name, prompt, moderated)
+	}
+	return moderated, nil
+}
+
+// complete sends the request to the AI provider and returns content of the only choice.
+// The request is limited by the configured timeout, and used tokens are counted for the persona.
+func (a *AI) complete(ctx context.Context, cfg *Config, personaName string, reqModel OpenrouterRequest) (string, error) {
 	jsonReq, err := json.Marshal(reqModel)
 	if err != nil {
 		return "", fmt.Errorf("marshal request: %w", err)
 	}
 
-	a.log.DebugContext(ctx, "sending request to ai provider", "url", a.cfg.BaseURL, "primaryModel", a.model, "fallbackModels", a.cfg.FallbackModels)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.BaseURL, bytes.NewBuffer(jsonReq))
+	ctx, cancel := context.WithTimeout(ctx, cfg.HTTP.Timeout)
+	defer cancel()
+
+	a.log.DebugContext(ctx, "sending request to ai provider", "url", cfg.BaseURL, "primaryModel", reqModel.Model, "fallbackModels", reqModel.Models, "params", reqModel.GenerationConfig)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.BaseURL, bytes.NewBuffer(jsonReq))
 	if err != nil {
 		return "", fmt.Errorf("new request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.cfg.APIKey))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.APIKey))
 
-	rsp, err := http.DefaultClient.Do(req)
+	rsp, err := a.httpClient().Do(req)
 	if err != nil {
-		return "", fmt.Errorf("new completion: %w", err)
+		return "", requestFailed(failureNetwork, fmt.Errorf("new completion: %w", err))
 	}
 	defer func() { _ = rsp.Body.Close() }()
 
 	if rsp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
-		return "", fmt.Errorf("unexpected status code: %d; body: %s", rsp.StatusCode, string(body))
+		return "", requestFailed(failureHTTP, fmt.Errorf("unexpected status code: %d; body: %s", rsp.StatusCode, string(body)))
 	}
 
 	var rspModel OpenrouterResponse
 	if err := json.NewDecoder(rsp.Body).Decode(&rspModel); err != nil {
-		return "", fmt.Errorf("decode response: %w", err)
+		return "", requestFailed(failureDecode, fmt.Errorf("decode response: %w", err))
 	}
 	a.log.DebugContext(ctx, "received response from ai provider", "usedModel", rspModel.Model, "usage", rspModel.Usage)
 
-	promptTokens.WithLabelValues(rspModel.Model).Add(float64(rspModel.Usage.PromptTokens))
-	completionTokens.WithLabelValues(rspModel.Model).Add(float64(rspModel.Usage.CompletionTokens))
-	totalTokens.WithLabelValues(rspModel.Model).Add(float64(rspModel.Usage.TotalTokens))
+	promptTokens.WithLabelValues(rspModel.Model, personaName).Add(float64(rspModel.Usage.PromptTokens))
+	completionTokens.WithLabelValues(rspModel.Model, personaName).Add(float64(rspModel.Usage.CompletionTokens))
+	totalTokens.WithLabelValues(rspModel.Model, personaName).Add(float64(rspModel.Usage.TotalTokens))
 
 	if len(rspModel.Choices) != 1 {
-		return "", fmt.Errorf("unexpected number of choices: %d", len(rspModel.Choices))
+		return "", requestFailed(failureInvalidResponse, fmt.Errorf("unexpected number of choices: %d", len(rspModel.Choices)))
 	}
 
 	choice := rspModel.Choices[0]
 	if choice.Message.Content == "" {
-		return "", fmt.Errorf("empty message content")
+		return "", requestFailed(failureInvalidResponse, errors.New("empty message content"))
 	}
 
-	message := strings.TrimSpace(choice.Message.Content)
-	a.log.DebugContext(ctx, "generated ai response", "response", message)
-	return message, nil
+	return strings.TrimSpace(choice.Message.Content), nil
 }
 
 type OpenrouterRequest struct {
 	Model    string    `json:"model"`
 	Models   []string  `json:"models,omitempty"`
 	Messages []Message `json:"messages"`
+
+	GenerationConfig
 }
 
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Parts replace Content in requests, to send images along with the text.
+	Parts []ContentPart `json:"-"`
 }
 
 type OpenrouterResponse struct {
@@ -0,0 +1,353 @@
+package ai
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DefaultCacheTTL        = 24 * time.Hour
+	DefaultCacheMaxEntries = 1000
+	DefaultCacheSimilarity = 0.8
+	DefaultCacheWindow     = time.Hour
+)
+
+// CacheConfig configures reuse of generated responses. Responses are cached per persona
+// under the normalized prompt, so messages that differ only in case, punctuation and spacing
+// get the same response. Responses that mention users or call the sender by name are not
+// cached, as they would address someone else when reused.
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+	// Path is the SQLite database the cache is persisted to. The cache is kept in memory
+	// only if it is empty.
+	Path    string             `yaml:"path"`
+	Similar SimilarCacheConfig `yaml:"similar"`
+}
+
+// SimilarCacheConfig configures reuse of responses to prompts that are not the same,
+// but close enough by trigram similarity.
+type SimilarCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Threshold is the minimal similarity of prompts, from 0 to 1.
+	Threshold float64 `yaml:"threshold"`
+	// TightAfter is the number of generations within Window after which the budget is
+	// considered tight and similar responses start being reused. Zero reuses them always.
+	TightAfter int           `yaml:"tight_after"`
+	Window     time.Duration `yaml:"window"`
+}
+
+func (c *CacheConfig) SetDefaults() {
+	if c.TTL == 0 {
+		c.TTL = DefaultCacheTTL
+	}
+	if c.MaxEntries == 0 {
+		c.MaxEntries = DefaultCacheMaxEntries
+	}
+	if c.Similar.Threshold == 0 {
+		c.Similar.Threshold = DefaultCacheSimilarity
+	}
+	if c.Similar.Window == 0 {
+		c.Similar.Window = DefaultCacheWindow
+	}
+}
+
+func (c *CacheConfig) Validate() error {
+	var errs []error
+	if c.TTL < 0 {
+		errs = append(errs, fmt.Errorf("cache.ttl must not be negative, got %s", c.TTL))
+	}
+	if c.MaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("cache.max_entries must not be negative, got %d", c.MaxEntries))
+	}
+	if c.Similar.Threshold <= 0 || c.Similar.Threshold > 1 {
+		errs = append(errs, fmt.Errorf("cache.similar.threshold must be between 0 and 1, got %g", c.Similar.Threshold))
+	}
+	if c.Similar.TightAfter < 0 {
+		errs = append(errs, fmt.Errorf("cache.similar.tight_after must not be negative, got %d", c.Similar.TightAfter))
+	}
+	if c.Similar.Window < 0 {
+		errs = append(errs, fmt.Errorf("cache.similar.window must not be negative, got %s", c.Similar.Window))
+	}
+	return errors.Join(errs...)
+}
+
+const cacheDDL = `
+CREATE TABLE IF NOT EXISTS ai_responses (
+    persona    TEXT    NOT NULL,
+    prompt     TEXT    NOT NULL,
+    response   TEXT    NOT NULL,
+    expires_at INTEGER NOT NULL,
+    PRIMARY KEY (persona, prompt)
+);
+`
+
+type cacheKey struct {
+	persona string
+	prompt  string
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	response  string
+	trigrams  map[string]struct{}
+	expiresAt time.Time
+}
+
+// responseCache is an LRU cache of generated responses, optionally backed by SQLite.
+type responseCache struct {
+	config CacheConfig
+	db     *sql.DB
+	log    *slog.Logger
+
+	mu          sync.Mutex
+	entries     map[cacheKey]*list.Element
+	lru         *list.List
+	generations []time.Time
+}
+
+func newResponseCache(config CacheConfig, log *slog.Logger) (*responseCache, error) {
+	c := &responseCache{
+		config:  config,
+		log:     log,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+	}
+	if config.Path == "" {
+		return c, nil
+	}
+
+	// "file://" would take the first element of a relative path for a host.
+	db, err := sql.Open("sqlite", "file:"+config.Path+"?mode=rwc&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("open cache db: %w", err)
+	}
+	c.db = db
+	if err := c.load(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// load drops expired responses from the database and puts the rest in memory.
+func (c *responseCache) load() error {
+	if _, err := c.db.Exec(cacheDDL); err != nil {
+		return fmt.Errorf("init cache db: %w", err)
+	}
+	if _, err := c.db.Exec("DELETE FROM ai_responses WHERE expires_at <= ?", time.Now().Unix()); err != nil {
+		return fmt.Errorf("delete expired responses: %w", err)
+	}
+
+	// Responses are loaded from the oldest, so that the newest ones are kept if the size
+	// limit was lowered.
+	rows, err := c.db.Query("SELECT persona, prompt, response, expires_at FROM ai_responses ORDER BY expires_at")
+	if err != nil {
+		return fmt.Errorf("select cached responses: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var evicted []cacheKey
+	for rows.Next() {
+		var (
+			key       cacheKey
+			response  string
+			expiresAt int64
+		)
+		if err := rows.Scan(&key.persona, &key.prompt, &response, &expiresAt); err != nil {
+			return fmt.Errorf("scan cached response: %w", err)
+		}
+		evicted = append(evicted, c.insert(key, response, time.Unix(expiresAt, 0))...)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("select cached responses: %w", err)
+	}
+	_ = rows.Close()
+
+	for _, key := range evicted {
+		if err := c.delete(context.Background(), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *responseCache) Close() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// get returns the response cached for the prompt. If there is none and similar responses
+// are allowed, the response to the most similar prompt is returned instead.
+func (c *responseCache) get(persona, prompt string) (response string, similar bool, ok bool) {
+	key := cacheKey{persona: persona, prompt: normalizePrompt(prompt)}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, found := c.entries[key]; found {
+		entry := elem.Value.(*cacheEntry)
+		if now.Before(entry.expiresAt) {
+			c.lru.MoveToFront(elem)
+			return entry.response, false, true
+		}
+	}
+
+	if !c.config.Similar.Enabled || !c.budgetTight(now) {
+		return "", false, false
+	}
+
+	trigrams := promptTrigrams(key.prompt)
+	var best *list.Element
+	bestSimilarity := c.config.Similar.Threshold
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*cacheEntry)
+		if entry.key.persona != persona || !now.Before(entry.expiresAt) {
+			continue
+		}
+		if similarity := jaccard(trigrams, entry.trigrams); similarity >= bestSimilarity {
+			best, bestSimilarity = elem, similarity
+		}
+	}
+	if best == nil {
+		return "", false, false
+	}
+	c.lru.MoveToFront(best)
+	return best.Value.(*cacheEntry).response, true, true
+}
+
+// countGeneration counts a paid generation towards the budget, whether its response is cached or not.
+func (c *responseCache) countGeneration(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations = append(c.generations, now)
+}
+
+// put caches the response.
+func (c *responseCache) put(ctx context.Context, persona, prompt, response string) {
+	key := cacheKey{persona: persona, prompt: normalizePrompt(prompt)}
+	expiresAt := time.Now().Add(c.config.TTL)
+
+	c.mu.Lock()
+	evicted := c.insert(key, response, expiresAt)
+	c.mu.Unlock()
+
+	if c.db == nil {
+		return
+	}
+	if _, err := c.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO ai_responses (persona, prompt, response, expires_at) VALUES (?, ?, ?, ?)",
+		key.persona, key.prompt, response, expiresAt.Unix(),
+	); err != nil {
+		c.log.ErrorContext(ctx, "failed to persist cached response", "error", err)
+	}
+	for _, key := range evicted {
+		if err := c.delete(ctx, key); err != nil {
+			c.log.ErrorContext(ctx, "failed to delete evicted response", "error", err)
+		}
+	}
+}
+
+func (c *responseCache) delete(ctx context.Context, key cacheKey) error {
+	_, err := c.db.ExecContext(ctx, "DELETE FROM ai_responses WHERE persona = ? AND prompt = ?", key.persona, key.prompt)
+	if err != nil {
+		return fmt.Errorf("delete cached response: %w", err)
+	}
+	return nil
+}
+
+// insert adds the entry and evicts least recently used ones over the size limit.
+// Must be called with mu held.
+func (c *responseCache) insert(key cacheKey, response string, expiresAt time.Time) (evicted []cacheKey) {
+	entry := &cacheEntry{
+		key:       key,
+		response:  response,
+		trigrams:  promptTrigrams(key.prompt),
+		expiresAt: expiresAt,
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.lru.PushFront(entry)
+	}
+
+	for c.config.MaxEntries > 0 && c.lru.Len() > c.config.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		key := oldest.Value.(*cacheEntry).key
+		delete(c.entries, key)
+		evicted = append(evicted, key)
+	}
+	return evicted
+}
+
+// budgetTight reports whether enough generations were made within the window to start
+// reusing similar responses. Must be called with mu held.
+func (c *responseCache) budgetTight(now time.Time) bool {
+	since := now.Add(-c.config.Similar.Window)
+	i := 0
+	for i < len(c.generations) && c.generations[i].Before(since) {
+		i++
+	}
+	c.generations = c.generations[i:]
+	return len(c.generations) >= c.config.Similar.TightAfter
+}
+
+// addressesUser reports whether the response mentions anyone or calls the user by name.
+func addressesUser(response string, user UserContext) bool {
+	if mentionRegexp.MatchString(response) {
+		return true
+	}
+	lower := strings.ToLower(response)
+	for _, name := range []string{user.Username, user.FirstName, user.LastName} {
+		if utf8.RuneCountInString(name) > 1 && strings.Contains(lower, strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePrompt lowercases the prompt and reduces it to words separated by single spaces.
+func normalizePrompt(prompt string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func promptTrigrams(prompt string) map[string]struct{} {
+	runes := []rune(" " + prompt + " ")
+	trigrams := make(map[string]struct{}, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams[string(runes[i:i+3])] = struct{}{}
+	}
+	return trigrams
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for trigram := range a {
+		if _, ok := b[trigram]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
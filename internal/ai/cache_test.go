@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheSharedBetweenUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    string
+		requests int
+	}{
+		{"neutral response", "Служу России!", 1},
+		{"mention of sender", "@ivan_ivanov, служу России!", 2},
+		{"name of sender", "Иван, служу России!", 2},
+		{"mention of someone else", "Служу России, @someone!", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, requests := fakeProvider(t, tt.reply)
+			a := newTestAI(t, url, func(c *Config) {
+				c.Cache.Enabled = true
+				c.Moderation.AllowMentions = true
+			})
+
+			other := UserContext{Username: "petr", FirstName: "Пётр"}
+			for _, user := range []UserContext{SampleUserContext(), other} {
+				if _, err := a.GenerateReply(context.Background(), "", "Что думаешь про СВО?", user, nil, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(*requests) != tt.requests {
+				t.Errorf("provider got %d requests, want %d", len(*requests), tt.requests)
+			}
+		})
+	}
+}
+
+func TestCachePersistence(t *testing.T) {
+	config := CacheConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "cache.db"), MaxEntries: 2}
+	config.SetDefaults()
+
+	cache, err := newResponseCache(config, newTestAI(t, "http://localhost", nil).log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cache.put(ctx, "default", "Первый вопрос", "first")
+	cache.put(ctx, "default", "второй вопрос", "second")
+	cache.put(ctx, "default", "третий вопрос", "third")
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = newResponseCache(config, cache.log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cache.Close() }()
+
+	if response, _, ok := cache.get("default", "первый вопрос"); ok {
+		t.Errorf("evicted response was loaded: %q", response)
+	}
+	if response, similar, ok := cache.get("default", "Второй  вопрос!"); !ok || similar || response != "second" {
+		t.Errorf("get() = %q, %t, %t, want exact match %q", response, similar, ok, "second")
+	}
+	if _, _, ok := cache.get("other", "третий вопрос"); ok {
+		t.Error("response of another persona was returned")
+	}
+}
+
+func TestCacheRelativePath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("data", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := CacheConfig{Enabled: true, Path: filepath.Join("data", "cache.db")}
+	config.SetDefaults()
+
+	log := newTestAI(t, "http://localhost", nil).log
+	cache, err := newResponseCache(config, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.put(context.Background(), "default", "вопрос", "ответ")
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = newResponseCache(config, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cache.Close() }()
+	if response, _, ok := cache.get("default", "вопрос"); !ok || response != "ответ" {
+		t.Errorf("get() = %q, %t, want %q", response, ok, "ответ")
+	}
+}
+
+func TestCacheCountsUncachedGenerations(t *testing.T) {
+	url, _ := fakeProvider(t, "@ivan_ivanov, служу России!")
+	a := newTestAI(t, url, func(c *Config) { c.Cache.Enabled = true })
+
+	ctx := context.Background()
+	history := []Turn{{User: SampleUserContext(), Text: "привет"}}
+	if _, err := a.GenerateReply(ctx, "", "Что думаешь про СВО?", SampleUserContext(), history, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.GenerateReply(ctx, "", "Что думаешь про СВО?", SampleUserContext(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := a.responseCache()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.lru.Len() != 0 {
+		t.Errorf("cache has %d responses, want none", cache.lru.Len())
+	}
+	if len(cache.generations) != 2 {
+		t.Errorf("cache counted %d generations, want 2", len(cache.generations))
+	}
+}
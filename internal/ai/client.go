@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	DefaultRequestTimeout  = time.Minute
+	DefaultConnectTimeout  = 10 * time.Second
+	DefaultResponseTimeout = 45 * time.Second
+	DefaultMaxIdleConns    = 10
+	DefaultIdleConnTimeout = 90 * time.Second
+)
+
+// HTTPConfig configures the client used to talk to the AI provider.
+type HTTPConfig struct {
+	// Timeout is the deadline of a whole request, including reading the response body.
+	Timeout time.Duration `yaml:"timeout"`
+	// ConnectTimeout limits establishing the connection and the TLS handshake.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	// ResponseTimeout limits waiting for response headers once the request is sent.
+	ResponseTimeout time.Duration `yaml:"response_timeout"`
+	// Proxy is the URL of the proxy to use. Proxy environment variables are used if it is empty.
+	Proxy string `yaml:"proxy"`
+	// CAFile is a PEM bundle of certificates trusted in addition to the system ones.
+	CAFile          string        `yaml:"ca_file"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	MaxConnsPerHost int           `yaml:"max_conns_per_host"`
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout"`
+}
+
+func (c *HTTPConfig) SetDefaults() {
+	if c.Timeout == 0 {
+		c.Timeout = DefaultRequestTimeout
+	}
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = DefaultConnectTimeout
+	}
+	if c.ResponseTimeout == 0 {
+		c.ResponseTimeout = DefaultResponseTimeout
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+}
+
+func (c *HTTPConfig) Validate() error {
+	var errs []error
+
+	checkDuration := func(name string, value time.Duration) {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("http.%s must not be negative, got %s", name, value))
+		}
+	}
+	checkDuration("timeout", c.Timeout)
+	checkDuration("connect_timeout", c.ConnectTimeout)
+	checkDuration("response_timeout", c.ResponseTimeout)
+	checkDuration("idle_conn_timeout", c.IdleConnTimeout)
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("http.max_idle_conns must not be negative, got %d", c.MaxIdleConns))
+	}
+	if c.MaxConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("http.max_conns_per_host must not be negative, got %d", c.MaxConnsPerHost))
+	}
+
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err != nil {
+			errs = append(errs, fmt.Errorf("http.proxy: %w", err))
+		} else if u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("http.proxy must be an absolute url, got %q", c.Proxy))
+		}
+	}
+	if c.CAFile != "" {
+		if _, err := loadCertPool(c.CAFile); err != nil {
+			errs = append(errs, fmt.Errorf("http.ca_file: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func newHTTPClient(config HTTPConfig) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy url: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CAFile != "" {
+		pool, err := loadCertPool(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 proxy,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   config.ConnectTimeout,
+			ResponseHeaderTimeout: config.ResponseTimeout,
+			MaxIdleConns:          config.MaxIdleConns,
+			MaxIdleConnsPerHost:   config.MaxIdleConns,
+			MaxConnsPerHost:       config.MaxConnsPerHost,
+			IdleConnTimeout:       config.IdleConnTimeout,
+			ForceAttemptHTTP2:     true,
+		},
+	}, nil
+}
+
+// loadCertPool returns system certificates along with the ones from the PEM file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+const (
+	failureTimeout         = "timeout"
+	failureNetwork         = "network"
+	failureHTTP            = "http"
+	failureDecode          = "decode"
+	failureInvalidResponse = "invalid_response"
+	failureRejected        = "rejected"
+	failureInternal        = "internal"
+)
+
+// requestError is an error of a request to the AI provider, labelled with the reason
+// the request failed for metrics.
+type requestError struct {
+	reason string
+	err    error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
+
+func requestFailed(reason string, err error) error {
+	if isTimeout(err) {
+		reason = failureTimeout
+	}
+	return &requestError{reason: reason, err: err}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// failureReason returns the label of the failed generation for metrics.
+func failureReason(err error) string {
+	var reqErr *requestError
+	switch {
+	case errors.Is(err, ErrResponseRejected):
+		return failureRejected
+	case errors.As(err, &reqErr):
+		return reqErr.reason
+	case isTimeout(err):
+		return failureTimeout
+	default:
+		return failureInternal
+	}
+}
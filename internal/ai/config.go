@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	DefaultBaseURL             = "https://openrouter.ai/api/v1/chat/completions"
+	DefaultResponseResetPeriod = time.Hour
+)
+
+type Config struct {
+	BaseURL             string        `yaml:"base_url"`
+	APIKey              string        `env:"AI_API_KEY"`
+	Model               string        `yaml:"model"`
+	FallbackModels      []string      `yaml:"fallback_models"`
+	ResponseResetPeriod time.Duration `yaml:"reset_period"`
+	SystemPrompt        string        `yaml:"system_prompt"`
+	// Personas are picked per chat with /persona, DefaultPersona is used in other chats.
+	// The top-level system prompt makes the persona named "default".
+	Personas       []PersonaConfig  `yaml:"personas"`
+	DefaultPersona string           `yaml:"default_persona"`
+	Input          InputConfig      `yaml:"input"`
+	Moderation     ModerationConfig `yaml:"moderation"`
+	HTTP           HTTPConfig       `yaml:"http"`
+	Cache          CacheConfig      `yaml:"cache"`
+	Images         ImagesConfig     `yaml:"images"`
+
+	// GenerationConfig applies to all personas, which can override single parameters.
+	GenerationConfig `yaml:",inline"`
+}
+
+// Enabled reports whether AI responses should be generated at all.
+func (c *Config) Enabled() bool {
+	return c.APIKey != ""
+}
+
+func (c *Config) SetDefaults() {
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultBaseURL
+	}
+	if c.ResponseResetPeriod == 0 {
+		c.ResponseResetPeriod = DefaultResponseResetPeriod
+	}
+	if c.DefaultPersona == "" {
+		if names := c.PersonaNames(); len(names) > 0 {
+			c.DefaultPersona = names[0]
+		}
+	}
+	c.Input.SetDefaults()
+	c.Moderation.SetDefaults()
+	c.HTTP.SetDefaults()
+	c.Cache.SetDefaults()
+	c.Images.SetDefaults()
+}
+
+// Validate reports all problems found in the config at once.
+// Most fields are only checked when AI is enabled.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.ResponseResetPeriod < 0 {
+		errs = append(errs, fmt.Errorf("reset_period must not be negative, got %s", c.ResponseResetPeriod))
+	}
+
+	if !c.Enabled() {
+		return errors.Join(errs...)
+	}
+
+	if u, err := url.Parse(c.BaseURL); err != nil {
+		errs = append(errs, fmt.Errorf("base_url: %w", err))
+	} else if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base_url must be an absolute http(s) url, got %q", c.BaseURL))
+	}
+
+	if c.Model == "" {
+		errs = append(errs, errors.New("model must not be empty"))
+	}
+
+	for i, model := range c.FallbackModels {
+		if model == "" {
+			errs = append(errs, fmt.Errorf("fallback_models[%d] must not be empty", i))
+		}
+	}
+
+	if c.ResponseResetPeriod == 0 {
+		errs = append(errs, errors.New("reset_period must be positive, zero disables the AI cooldown"))
+	}
+
+	if err := c.GenerationConfig.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.SystemPrompt == "" && len(c.Personas) == 0 {
+		errs = append(errs, errors.New("either system_prompt or personas must be set"))
+	} else if c.SystemPrompt != "" {
+		if _, err := RenderSystemPrompt(c, DefaultPersona, SampleUserContext()); err != nil {
+			errs = append(errs, fmt.Errorf("system_prompt: %w", err))
+		}
+	}
+
+	seenPersonas := make(map[string]bool, len(c.Personas))
+	if c.SystemPrompt != "" {
+		seenPersonas[DefaultPersona] = true
+	}
+	for i, persona := range c.Personas {
+		switch {
+		case persona.Name == "":
+			errs = append(errs, fmt.Errorf("personas[%d].name must not be empty", i))
+		case persona.Name == RandomPersona:
+			errs = append(errs, fmt.Errorf("personas[%d].name %q is reserved", i, persona.Name))
+		case seenPersonas[persona.Name]:
+			errs = append(errs, fmt.Errorf("personas[%d]: duplicate persona %q", i, persona.Name))
+		}
+		seenPersonas[persona.Name] = true
+
+		if err := persona.Validate(); err != nil {
+			errs = append(errs, prefixErrors(fmt.Sprintf("personas[%d]", i), err))
+		}
+	}
+	if c.DefaultPersona != "" && !c.HasPersona(c.DefaultPersona) {
+		errs = append(errs, fmt.Errorf("default_persona: unknown persona %q", c.DefaultPersona))
+	}
+
+	if err := c.Input.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Moderation.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.HTTP.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Cache.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Images.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SampleUserContext returns user context used to dry-render the system prompt.
+func SampleUserContext() UserContext {
+	return UserContext{
+		Username:  "ivan_ivanov",
+		FirstName: "Иван",
+		LastName:  "Иванов",
+	}
+}
+
+func prefixErrors(section string, err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return fmt.Errorf("%s.%w", section, err)
+	}
+
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, fmt.Errorf("%s.%w", section, err))
+	}
+	return errors.Join(errs...)
+}
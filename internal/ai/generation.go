@@ -0,0 +1,155 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// GenerationConfig holds sampling parameters forwarded to the provider. Parameters that
+// are not set are left to provider defaults.
+type GenerationConfig struct {
+	Temperature      *float64 `yaml:"temperature" json:"temperature,omitempty"`
+	TopP             *float64 `yaml:"top_p" json:"top_p,omitempty"`
+	MaxTokens        int      `yaml:"max_tokens" json:"max_tokens,omitempty"`
+	Stop             []string `yaml:"stop" json:"stop,omitempty"`
+	PresencePenalty  *float64 `yaml:"presence_penalty" json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `yaml:"frequency_penalty" json:"frequency_penalty,omitempty"`
+	Seed             *int     `yaml:"seed" json:"seed,omitempty"`
+	// Provider configures routing between providers serving the model on OpenRouter.
+	Provider *ProviderPreferences `yaml:"provider" json:"provider,omitempty"`
+}
+
+// ProviderPreferences follow provider routing of OpenRouter.
+type ProviderPreferences struct {
+	Order             []string `yaml:"order" json:"order,omitempty"`
+	Ignore            []string `yaml:"ignore" json:"ignore,omitempty"`
+	AllowFallbacks    *bool    `yaml:"allow_fallbacks" json:"allow_fallbacks,omitempty"`
+	RequireParameters *bool    `yaml:"require_parameters" json:"require_parameters,omitempty"`
+	DataCollection    string   `yaml:"data_collection" json:"data_collection,omitempty"`
+	Sort              string   `yaml:"sort" json:"sort,omitempty"`
+}
+
+const maxStopSequences = 4
+
+var (
+	dataCollectionPolicies = []string{"allow", "deny"}
+	providerSorts          = []string{"price", "throughput", "latency"}
+)
+
+func (g *GenerationConfig) Validate() error {
+	var errs []error
+
+	checkRange := func(name string, value *float64, min, max float64) {
+		if value != nil && (*value < min || *value > max) {
+			errs = append(errs, fmt.Errorf("%s must be between %g and %g, got %g", name, min, max, *value))
+		}
+	}
+	checkRange("temperature", g.Temperature, 0, 2)
+	checkRange("top_p", g.TopP, 0, 1)
+	checkRange("presence_penalty", g.PresencePenalty, -2, 2)
+	checkRange("frequency_penalty", g.FrequencyPenalty, -2, 2)
+
+	if g.MaxTokens < 0 {
+		errs = append(errs, fmt.Errorf("max_tokens must not be negative, got %d", g.MaxTokens))
+	}
+	if len(g.Stop) > maxStopSequences {
+		errs = append(errs, fmt.Errorf("stop must contain at most %d sequences, got %d", maxStopSequences, len(g.Stop)))
+	}
+	for i, stop := range g.Stop {
+		if stop == "" {
+			errs = append(errs, fmt.Errorf("stop[%d] must not be empty", i))
+		}
+	}
+
+	if p := g.Provider; p != nil {
+		if p.DataCollection != "" && !slices.Contains(dataCollectionPolicies, p.DataCollection) {
+			errs = append(errs, fmt.Errorf("provider.data_collection must be one of %v, got %q", dataCollectionPolicies, p.DataCollection))
+		}
+		if p.Sort != "" && !slices.Contains(providerSorts, p.Sort) {
+			errs = append(errs, fmt.Errorf("provider.sort must be one of %v, got %q", providerSorts, p.Sort))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// merge returns the parameters with the ones set in override replacing them.
+func (g GenerationConfig) merge(override GenerationConfig) GenerationConfig {
+	if override.Temperature != nil {
+		g.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		g.TopP = override.TopP
+	}
+	if override.MaxTokens != 0 {
+		g.MaxTokens = override.MaxTokens
+	}
+	if override.Stop != nil {
+		g.Stop = override.Stop
+	}
+	if override.PresencePenalty != nil {
+		g.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		g.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.Seed != nil {
+		g.Seed = override.Seed
+	}
+	if override.Provider != nil {
+		g.Provider = override.Provider
+	}
+	return g
+}
+
+// LogValue logs only the parameters that are set.
+func (g GenerationConfig) LogValue() slog.Value {
+	var attrs []slog.Attr
+	addFloat := func(key string, value *float64) {
+		if value != nil {
+			attrs = append(attrs, slog.Float64(key, *value))
+		}
+	}
+	addFloat("temperature", g.Temperature)
+	addFloat("topP", g.TopP)
+	if g.MaxTokens != 0 {
+		attrs = append(attrs, slog.Int("maxTokens", g.MaxTokens))
+	}
+	if len(g.Stop) > 0 {
+		attrs = append(attrs, slog.Any("stop", g.Stop))
+	}
+	addFloat("presencePenalty", g.PresencePenalty)
+	addFloat("frequencyPenalty", g.FrequencyPenalty)
+	if g.Seed != nil {
+		attrs = append(attrs, slog.Int("seed", *g.Seed))
+	}
+	if g.Provider != nil {
+		attrs = append(attrs, slog.Any("provider", *g.Provider))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (p ProviderPreferences) LogValue() slog.Value {
+	var attrs []slog.Attr
+	if len(p.Order) > 0 {
+		attrs = append(attrs, slog.Any("order", p.Order))
+	}
+	if len(p.Ignore) > 0 {
+		attrs = append(attrs, slog.Any("ignore", p.Ignore))
+	}
+	if p.AllowFallbacks != nil {
+		attrs = append(attrs, slog.Bool("allowFallbacks", *p.AllowFallbacks))
+	}
+	if p.RequireParameters != nil {
+		attrs = append(attrs, slog.Bool("requireParameters", *p.RequireParameters))
+	}
+	if p.DataCollection != "" {
+		attrs = append(attrs, slog.String("dataCollection", p.DataCollection))
+	}
+	if p.Sort != "" {
+		attrs = append(attrs, slog.String("sort", p.Sort))
+	}
+	return slog.GroupValue(attrs...)
+}
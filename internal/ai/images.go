@@ -0,0 +1,141 @@
+package ai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ImageFormat is the format of image content parts, which differs between providers.
+// It only changes the shape of image parts: requests are always sent in the OpenAI-compatible
+// chat completions format of OpenRouter, so ImageFormatAnthropic is for providers that pass
+// Anthropic image blocks through it, not for the Anthropic Messages API itself.
+type ImageFormat string
+
+const (
+	ImageFormatOpenAI    ImageFormat = "openai"
+	ImageFormatAnthropic ImageFormat = "anthropic"
+)
+
+var ImageFormats = []ImageFormat{ImageFormatOpenAI, ImageFormatAnthropic}
+
+const DefaultImageMaxSize = 1 << 20
+
+// supportedImageTypes are media types accepted by vision models of both formats.
+var supportedImageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// ImagesConfig configures passing photos and sticker thumbnails from messages to the model.
+type ImagesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Models are the models that accept images. Images are neither downloaded nor sent if
+	// the model of the persona is not one of them, and other fallback models are skipped
+	// for messages with images.
+	Models []string `yaml:"models"`
+	// Format is the shape of image content parts within OpenRouter chat completion requests.
+	Format ImageFormat `yaml:"format"`
+	// MaxSize is the maximal size of an image in bytes. Larger images are not downloaded.
+	MaxSize int `yaml:"max_size"`
+}
+
+func (c *ImagesConfig) SetDefaults() {
+	if c.Format == "" {
+		c.Format = ImageFormatOpenAI
+	}
+	if c.MaxSize == 0 {
+		c.MaxSize = DefaultImageMaxSize
+	}
+}
+
+func (c *ImagesConfig) Validate() error {
+	var errs []error
+	if !slices.Contains(ImageFormats, c.Format) {
+		errs = append(errs, fmt.Errorf("images.format must be one of %v, got %q", ImageFormats, c.Format))
+	}
+	if c.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("images.max_size must not be negative, got %d", c.MaxSize))
+	}
+	if c.Enabled && len(c.Models) == 0 {
+		errs = append(errs, errors.New("images.models must not be empty when images are enabled"))
+	}
+	return errors.Join(errs...)
+}
+
+// accepts reports whether images can be sent to the model.
+func (c *ImagesConfig) accepts(model string) bool {
+	return c.Enabled && slices.Contains(c.Models, model)
+}
+
+// Image is an image attached to the prompt.
+type Image struct {
+	MediaType string
+	Data      []byte
+}
+
+// ContentPart is a part of multimodal message content. Only fields of its type are set.
+type ContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// ImageURL is set for images in the OpenAI format.
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+	// Source is set for images in the Anthropic content block format.
+	Source *ImageSource `json:"source,omitempty"`
+}
+
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+type ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// contentParts returns the images followed by the text, skipping images of unsupported types.
+func contentParts(format ImageFormat, text string, images []Image) []ContentPart {
+	parts := make([]ContentPart, 0, len(images)+1)
+	for _, image := range images {
+		if !slices.Contains(supportedImageTypes, image.MediaType) {
+			continue
+		}
+
+		data := base64.StdEncoding.EncodeToString(image.Data)
+		switch format {
+		case ImageFormatAnthropic:
+			parts = append(parts, ContentPart{
+				Type: "image",
+				Source: &ImageSource{
+					Type:      "base64",
+					MediaType: image.MediaType,
+					Data:      data,
+				},
+			})
+		default:
+			parts = append(parts, ContentPart{
+				Type:     "image_url",
+				ImageURL: &ImageURL{URL: fmt.Sprintf("data:%s;base64,%s", image.MediaType, data)},
+			})
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return append(parts, ContentPart{Type: "text", Text: text})
+}
+
+// MarshalJSON sends Parts as the content if they are set, and Content otherwise.
+func (m Message) MarshalJSON() ([]byte, error) {
+	if len(m.Parts) == 0 {
+		type plainMessage Message
+		return json.Marshal(plainMessage(m))
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}{
+		Role:    m.Role,
+		Content: m.Parts,
+	})
+}
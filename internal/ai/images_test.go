@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func imagesConfig(c *Config) {
+	c.Personas = []PersonaConfig{
+		{Name: "seer", SystemPrompt: "Ты провидец.", Model: "vision"},
+		{Name: "blind", SystemPrompt: "Ты слепой.", Model: "text"},
+		{Name: "plain", SystemPrompt: "Ты обычный."},
+	}
+	c.Images = ImagesConfig{Enabled: true, Models: []string{"model", "vision", "vision-fallback"}}
+}
+
+func TestAcceptsImages(t *testing.T) {
+	a := newTestAI(t, "http://localhost", imagesConfig)
+	cfg, _, _ := a.snapshot()
+
+	for persona, want := range map[string]bool{
+		DefaultPersona: true,
+		"seer":         true,
+		"blind":        false,
+		"plain":        true,
+	} {
+		if got := cfg.AcceptsImages(persona); got != want {
+			t.Errorf("AcceptsImages(%q) = %v, want %v", persona, got, want)
+		}
+	}
+
+	cfg.Images.Enabled = false
+	if cfg.AcceptsImages("seer") {
+		t.Error("images are accepted while disabled")
+	}
+}
+
+func TestPickPersona(t *testing.T) {
+	a := newTestAI(t, "http://localhost", imagesConfig)
+	cfg, _, _ := a.snapshot()
+
+	for name, want := range map[string]string{
+		"":        DefaultPersona,
+		"unknown": DefaultPersona,
+		"seer":    "seer",
+	} {
+		if got := cfg.PickPersona(name); got != want {
+			t.Errorf("PickPersona(%q) = %q, want %q", name, got, want)
+		}
+	}
+	for range 20 {
+		if got := cfg.PickPersona(RandomPersona); !slices.Contains(cfg.PersonaNames(), got) {
+			t.Errorf("PickPersona(%q) = %q, want one of %v", RandomPersona, got, cfg.PersonaNames())
+		}
+	}
+}
+
+func TestFallbackModelsWithImages(t *testing.T) {
+	var models [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Models []string `json:"models"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		models = append(models, req.Models)
+		_ = json.NewEncoder(w).Encode(OpenrouterResponse{
+			Model:   "fake",
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "Вижу!"}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	a := newTestAI(t, server.URL, func(c *Config) {
+		imagesConfig(c)
+		c.FallbackModels = []string{"text-fallback", "vision-fallback"}
+	})
+	image := Image{MediaType: "image/png", Data: []byte("png")}
+
+	tests := []struct {
+		name    string
+		persona string
+		images  []Image
+		want    []string
+	}{
+		{"no images", "seer", nil, []string{"text-fallback", "vision-fallback"}},
+		{"images", "seer", []Image{image}, []string{"vision-fallback"}},
+		{"images dropped", "blind", []Image{image}, []string{"text-fallback", "vision-fallback"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.GenerateReply(context.Background(), tt.persona, "что на фото?", SampleUserContext(), nil, tt.images); err != nil {
+				t.Fatal(err)
+			}
+			if got := models[len(models)-1]; !slices.Equal(got, tt.want) {
+				t.Errorf("fallback models are %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if cfg, _, _ := a.snapshot(); !slices.Equal(cfg.FallbackModels, []string{"text-fallback", "vision-fallback"}) {
+		t.Errorf("fallback models in config were changed to %v", cfg.FallbackModels)
+	}
+}
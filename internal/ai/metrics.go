@@ -6,7 +6,15 @@ import (
 )
 
 const (
-	modelLabel = "model"
+	modelLabel   = "model"
+	personaLabel = "persona"
+	reasonLabel  = "reason"
+	matchLabel   = "match"
+)
+
+const (
+	exactMatch   = "exact"
+	similarMatch = "similar"
 )
 
 var (
@@ -17,11 +25,28 @@ var (
 		},
 	)
 
-	failedGenerations = promauto.NewCounter(
+	failedGenerations = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "failed_generations_count",
 			Help: "Number of failed generations",
 		},
+		[]string{reasonLabel},
+	)
+
+	rejectedGenerations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rejected_generations_count",
+			Help: "Number of generated responses rejected by moderation",
+		},
+		[]string{reasonLabel},
+	)
+
+	cachedResponses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cached_responses_count",
+			Help: "Number of responses served from cache instead of being generated",
+		},
+		[]string{matchLabel},
 	)
 
 	generationDurationSeconds = promauto.NewHistogram(
@@ -37,7 +62,7 @@ var (
 			Name: "prompt_tokens_count",
 			Help: "Number of prompt tokens used",
 		},
-		[]string{modelLabel},
+		[]string{modelLabel, personaLabel},
 	)
 
 	completionTokens = promauto.NewCounterVec(
@@ -45,7 +70,7 @@ var (
 			Name: "completion_tokens_count",
 			Help: "Number of completion tokens used",
 		},
-		[]string{modelLabel},
+		[]string{modelLabel, personaLabel},
 	)
 
 	totalTokens = promauto.NewCounterVec(
@@ -53,6 +78,6 @@ var (
 			Name: "total_tokens_count",
 			Help: "Total number of tokens used",
 		},
-		[]string{modelLabel},
+		[]string{modelLabel, personaLabel},
 	)
 )
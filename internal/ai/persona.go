@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math/rand/v2"
+	"slices"
+)
+
+const (
+	// DefaultPersona is the persona made of the top-level system prompt and model.
+	DefaultPersona = "default"
+	// RandomPersona picks one of the personas at random for every generation.
+	RandomPersona = "random"
+)
+
+// PersonaConfig is a character the bot can play. Model and generation parameters
+// that are not set are taken from the top-level config.
+type PersonaConfig struct {
+	Name         string `yaml:"name"`
+	SystemPrompt string `yaml:"system_prompt"`
+	Model        string `yaml:"model"`
+
+	GenerationConfig `yaml:",inline"`
+	// Examples are dialogue turns sent before the user message to show the model how to answer.
+	Examples []ExampleTurn `yaml:"examples"`
+}
+
+type ExampleTurn struct {
+	User      string `yaml:"user"`
+	Assistant string `yaml:"assistant"`
+}
+
+func (p *PersonaConfig) Validate() error {
+	var errs []error
+
+	if p.SystemPrompt == "" {
+		errs = append(errs, errors.New("system_prompt must not be empty"))
+	} else if systemPrompt, err := parseSystemPrompt(p.SystemPrompt); err != nil {
+		errs = append(errs, fmt.Errorf("system_prompt: %w", err))
+	} else if _, err := renderSystemPrompt(systemPrompt, SampleUserContext()); err != nil {
+		errs = append(errs, fmt.Errorf("system_prompt: %w", err))
+	}
+	if err := p.GenerationConfig.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	for i, example := range p.Examples {
+		if example.User == "" || example.Assistant == "" {
+			errs = append(errs, fmt.Errorf("examples[%d]: user and assistant must not be empty", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// AllPersonas returns configured personas along with the default one, if the top-level
+// system prompt is set.
+func (c *Config) AllPersonas() []PersonaConfig {
+	personas := make([]PersonaConfig, 0, len(c.Personas)+1)
+	if c.SystemPrompt != "" {
+		personas = append(personas, PersonaConfig{
+			Name:         DefaultPersona,
+			SystemPrompt: c.SystemPrompt,
+		})
+	}
+	return append(personas, c.Personas...)
+}
+
+// PersonaNames returns names of all personas that can be picked for a chat.
+func (c *Config) PersonaNames() []string {
+	var names []string
+	for _, persona := range c.AllPersonas() {
+		names = append(names, persona.Name)
+	}
+	return names
+}
+
+// HasPersona reports whether name can be used as a persona of a chat, including RandomPersona.
+func (c *Config) HasPersona(name string) bool {
+	return name == RandomPersona || slices.Contains(c.PersonaNames(), name)
+}
+
+type persona struct {
+	PersonaConfig
+	systemPrompt *template.Template
+}
+
+func newPersonas(config *Config) (map[string]*persona, error) {
+	personas := make(map[string]*persona, len(config.Personas)+1)
+	for _, personaConfig := range config.AllPersonas() {
+		systemPrompt, err := parseSystemPrompt(personaConfig.SystemPrompt)
+		if err != nil {
+			return nil, fmt.Errorf("persona %q: %w", personaConfig.Name, err)
+		}
+		if personaConfig.Model == "" {
+			personaConfig.Model = config.Model
+		}
+		personaConfig.GenerationConfig = config.GenerationConfig.merge(personaConfig.GenerationConfig)
+		personas[personaConfig.Name] = &persona{
+			PersonaConfig: personaConfig,
+			systemPrompt:  systemPrompt,
+		}
+	}
+	return personas, nil
+}
+
+// PickPersona resolves the persona name to one of the personas, falling back to the default
+// persona if the name is empty or unknown, and picking a random one for RandomPersona.
+func (c *Config) PickPersona(name string) string {
+	names := c.PersonaNames()
+	if name == "" || name != RandomPersona && !slices.Contains(names, name) {
+		name = c.DefaultPersona
+	}
+	if name == RandomPersona {
+		name = names[rand.IntN(len(names))]
+	}
+	return name
+}
+
+// AcceptsImages reports whether images can be sent to the model of the persona, which must
+// be resolved with PickPersona.
+func (c *Config) AcceptsImages(personaName string) bool {
+	model := c.Model
+	for _, persona := range c.Personas {
+		if persona.Name == personaName && persona.Model != "" {
+			model = persona.Model
+		}
+	}
+	return c.Images.accepts(model)
+}
+
+// pickPersona returns the persona the name resolves to with PickPersona.
+func (a *AI) pickPersona(cfg *Config, personas map[string]*persona, name string) *persona {
+	return personas[cfg.PickPersona(name)]
+}
@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
+)
+
+// typingInterval is how often the typing action is repeated while a response is generated,
+// as Telegram shows it for 5 seconds at most.
+const typingInterval = 5 * time.Second
+
+const (
+	aiJobGenerated = "generated"
+	aiJobFallback  = "fallback"
+	aiJobTimeout   = "timeout"
+	aiJobDropped   = "dropped"
+)
+
+// aiJobPool runs AI generations off the worker path. At most concurrency jobs run at once,
+// and at most queueSize more wait for a free slot.
+type aiJobPool struct {
+	slots chan struct{}
+	queue chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newAIJobPool(concurrency, queueSize int) *aiJobPool {
+	return &aiJobPool{
+		slots: make(chan struct{}, concurrency),
+		queue: make(chan struct{}, concurrency+queueSize),
+	}
+}
+
+// submit runs the job in the background once there is a free slot. If ctx is done before
+// that, expired is called instead. It reports false without running either if the queue is full.
+func (p *aiJobPool) submit(ctx context.Context, job, expired func()) bool {
+	select {
+	case p.queue <- struct{}{}:
+	default:
+		return false
+	}
+
+	runningAIJobs.Inc()
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.queue
+			runningAIJobs.Dec()
+			p.wg.Done()
+		}()
+
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			expired()
+			return
+		}
+		defer func() { <-p.slots }()
+		job()
+	}()
+	return true
+}
+
+// wait blocks until all submitted jobs finish.
+func (p *aiJobPool) wait() {
+	p.wg.Wait()
+}
+
+// scheduleAIReply shows that the bot is typing and generates the response in the AI job pool,
+// which sends it once it is ready. If the response is not generated before the deadline,
+// including time spent in the queue, or the queue is full, a fallback response is sent instead.
+func (w *worker) scheduleAIReply(ctx context.Context, msg *telego.Message, trigger trigger, pending *aiResponse) error {
+	w.sendTyping(ctx, msg)
+
+	// The job runs concurrently with the worker, so it gets its own rng.
+	job := w.jobWorker()
+	generationCtx, cancel := context.WithTimeout(ctx, w.config().AIJobs.Deadline)
+	// The bot keeps typing while the job waits in the queue too.
+	stopTyping := job.keepTyping(generationCtx, msg)
+	scheduled := w.aiJobs.submit(generationCtx, func() {
+		defer cancel()
+		images := job.downloadImages(generationCtx, pending.imageFileID)
+		text, err := job.ai.GenerateReply(generationCtx, pending.persona, pending.prompt, pending.userContext, pending.history, images)
+		stopTyping()
+		job.finishAIReply(ctx, msg, trigger, pending, text, err)
+	}, func() {
+		defer cancel()
+		stopTyping()
+		job.finishAIReply(ctx, msg, trigger, pending, "", generationCtx.Err())
+	})
+	if scheduled {
+		return nil
+	}
+	stopTyping()
+	cancel()
+
+	w.log.WarnContext(ctx, "ai job queue is full, using fallback response")
+	w.cache.Delete(aiSenderKey(msg.From.ID))
+	aiJobResults.WithLabelValues(aiJobDropped).Inc()
+	return w.sendReplies(ctx, msg, []reply{{response: w.aiFallbackResponse(ctx, msg, trigger, pending), trigger: trigger}})
+}
+
+// jobWorker returns a copy of the worker for an AI job. The copy has its own rng seeded
+// from the worker one, as rand.Rand is not safe for concurrent use. Must be called
+// from the worker goroutine.
+func (w *worker) jobWorker() *worker {
+	job := *w
+	job.rng = rand.New(rand.NewPCG(w.rng.Uint64(), w.rng.Uint64()))
+	return &job
+}
+
+// finishAIReply sends the generated response, or a fallback one if generation failed.
+// Nothing is sent if the bot is stopping.
+func (w *worker) finishAIReply(ctx context.Context, msg *telego.Message, trigger trigger, pending *aiResponse, text string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	response, status := w.aiJobResponse(ctx, msg, trigger, pending, text, err)
+	if generated, ok := response.(*textResponse); ok && status == aiJobGenerated {
+		history := append(slices.Clone(pending.history),
+			ai.Turn{User: pending.userContext, Text: pending.prompt},
+			ai.Turn{FromBot: true, Text: text},
+		)
+		generated.sent = func(sent *telego.Message) {
+			w.rememberConversation(msg.Chat.ID, sent.MessageID, history)
+		}
+	}
+	aiJobResults.WithLabelValues(status).Inc()
+	if err := w.sendReplies(ctx, msg, []reply{{response: response, trigger: trigger}}); err != nil {
+		w.log.ErrorContext(ctx, "failed to send ai reply", "error", err)
+	}
+}
+
+// aiJobResponse makes the response to send for the result of the generation, along with
+// the status of the job.
+func (w *worker) aiJobResponse(ctx context.Context, msg *telego.Message, trigger trigger, pending *aiResponse, text string, err error) (triggerResponse, string) {
+	switch {
+	case err == nil:
+		return &textResponse{
+			triggerResponseBase: triggerResponseBase{
+				t: trigger, typ: aiGenerated,
+			},
+			text: text,
+		}, aiJobGenerated
+
+	case errors.Is(err, ai.ErrResponseRejected):
+		// The cooldown is kept, so that users can't retry prompts that make the AI misbehave.
+		w.log.WarnContext(ctx, "ai response rejected, using fallback response", "error", err)
+		return w.aiFallbackResponse(ctx, msg, trigger, pending), aiJobFallback
+
+	case errors.Is(err, context.DeadlineExceeded):
+		w.cache.Delete(aiSenderKey(msg.From.ID))
+		w.log.WarnContext(ctx, "ai response missed the deadline, using fallback response", "error", err)
+		return w.aiFallbackResponse(ctx, msg, trigger, pending), aiJobTimeout
+
+	default:
+		w.cache.Delete(aiSenderKey(msg.From.ID))
+		w.log.ErrorContext(ctx, "failed to generate ai response, using fallback response", "error", err)
+		return w.aiFallbackResponse(ctx, msg, trigger, pending), aiJobFallback
+	}
+}
+
+// aiFallbackResponse is sent when the AI response can't be generated. Responses to triggers
+// fall back to the default response, while replies to messages addressed to the bot have
+// no trigger to respond to, so a neutral error text is sent for them.
+func (w *worker) aiFallbackResponse(ctx context.Context, msg *telego.Message, trigger trigger, pending *aiResponse) triggerResponse {
+	if pending.source == "" {
+		return w.makeDefaultResponse(ctx, trigger, msg)
+	}
+	return &textResponse{
+		triggerResponseBase: triggerResponseBase{
+			t: trigger, typ: regular,
+		},
+		text: w.render(ctx, msg, texts.AIReplyFailed, nil),
+	}
+}
+
+func (w *worker) sendTyping(ctx context.Context, msg *telego.Message) {
+	err := w.api.SendChatAction(&telego.SendChatActionParams{
+		ChatID:          msg.Chat.ChatID(),
+		MessageThreadID: msg.MessageThreadID,
+		Action:          telego.ChatActionTyping,
+	})
+	if err != nil {
+		w.log.WarnContext(ctx, "failed to send typing action", "error", err)
+	}
+}
+
+// keepTyping repeats the typing action until ctx is done or the returned function is called.
+func (w *worker) keepTyping(ctx context.Context, msg *telego.Message) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(typingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.sendTyping(ctx, msg)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
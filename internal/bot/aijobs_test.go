@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAIJobPoolExpiresQueuedJobs(t *testing.T) {
+	pool := newAIJobPool(1, 1)
+	release := make(chan struct{})
+	running := make(chan struct{})
+	if !pool.submit(context.Background(), func() {
+		close(running)
+		<-release
+	}, func() { t.Error("running job expired") }) {
+		t.Fatal("first job was not scheduled")
+	}
+	<-running
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	expired := make(chan struct{})
+	if !pool.submit(ctx, func() { t.Error("expired job ran") }, func() { close(expired) }) {
+		t.Fatal("second job was not queued")
+	}
+	if pool.submit(context.Background(), func() {}, func() {}) {
+		t.Error("job was scheduled over the queue size")
+	}
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("queued job did not expire while waiting for a slot")
+	}
+
+	close(release)
+	pool.wait()
+}
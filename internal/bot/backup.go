@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/logging"
+)
+
+var errBackupsDisabled = errors.New("backups are not configured")
+
+type backuper struct {
+	mu      sync.Mutex
+	config  func() *Config
+	storage db.Storage
+	log     *slog.Logger
+}
+
+func newBackuper(config func() *Config, storage db.Storage) *backuper {
+	return &backuper{
+		config:  config,
+		storage: storage,
+		log:     logging.New("backup"),
+	}
+}
+
+// Backup makes a backup and prunes old ones according to the retention policy.
+// Concurrent calls are serialized.
+func (b *backuper) Backup(ctx context.Context) (path string, err error) {
+	config := b.config().Backup
+	if !config.Enabled() {
+		return "", errBackupsDisabled
+	}
+	sqlite, ok := db.AsSqlite(b.storage)
+	if !ok {
+		return "", errors.New("backups are only supported for sqlite storage")
+	}
+
+	defer func() {
+		if err != nil {
+			backups.WithLabelValues("failure").Inc()
+		} else {
+			backups.WithLabelValues("success").Inc()
+			lastBackupTimestamp.SetToCurrentTime()
+		}
+	}()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	path, err = sqlite.BackupToDir(ctx, config.Dir, now)
+	if err != nil {
+		return "", fmt.Errorf("backup database: %w", err)
+	}
+	b.log.InfoContext(ctx, "database backed up", "path", path)
+
+	removed, err := db.PruneBackups(config.Dir, config.KeepLast, config.MaxAge, now)
+	if len(removed) > 0 {
+		b.log.InfoContext(ctx, "removed old backups", "paths", removed)
+	}
+	if err != nil {
+		// The backup itself succeeded, failing to clean up is not fatal.
+		b.log.WarnContext(ctx, "failed to prune old backups", "error", err)
+	}
+
+	return path, nil
+}
+
+// Run makes backups periodically until ctx is done. Interval is re-read from config
+// after every backup, so changes are applied on config reload.
+func (b *backuper) Run(ctx context.Context) {
+	const disabledCheckInterval = time.Minute
+
+	for {
+		interval := disabledCheckInterval
+		config := b.config().Backup
+		enabled := config.Enabled() && config.Interval > 0
+		if enabled {
+			interval = config.Interval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		if !enabled {
+			continue
+		}
+		if _, err := b.Backup(ctx); err != nil {
+			b.log.ErrorContext(ctx, "scheduled backup failed", "error", err)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mymmrac/telego"
@@ -17,7 +19,8 @@ import (
 )
 
 type Bot struct {
-	config               *Config
+	state                atomic.Pointer[state]
+	configPath           string
 	workerCount          int
 	cacheDuration        time.Duration
 	cacheCleanupInterval time.Duration
@@ -33,7 +36,6 @@ func NewBot(config *Config, opts ...Option) (*Bot, error) {
 	}
 
 	b := &Bot{
-		config:               config,
 		workerCount:          4,
 		cacheDuration:        time.Hour * 1,
 		cacheCleanupInterval: time.Minute * 5,
@@ -42,12 +44,22 @@ func NewBot(config *Config, opts ...Option) (*Bot, error) {
 		api: api,
 	}
 
+	st, err := newState(config)
+	if err != nil {
+		return nil, err
+	}
+	b.state.Store(st)
+
 	for _, opt := range opts {
 		opt(b)
 	}
 	return b, nil
 }
 
+func (b *Bot) config() *Config {
+	return b.state.Load().config
+}
+
 func (b *Bot) Run(ctx context.Context) error {
 	log := logging.New("bot")
 	log.DebugContext(ctx, "running in debug mode")
@@ -55,28 +67,49 @@ func (b *Bot) Run(ctx context.Context) error {
 	cache := cache.New(b.cacheDuration, b.cacheCleanupInterval)
 	workerUpdatesChan := make(chan telego.Update, 1000)
 
+	config := b.config()
+
 	var aiHandler *ai.AI
-	if b.config.AI.APIKey == "" {
+	if !config.AI.Enabled() {
 		log.WarnContext(ctx, "AI API key is not set, AI responses will be disabled")
 	} else {
 		var err error
-		aiHandler, err = ai.NewAI(b.config.AI)
+		aiHandler, err = ai.NewAI(config.AI)
 		if err != nil {
 			return fmt.Errorf("create ai handler: %w", err)
 		}
+		defer func() {
+			if err := aiHandler.Close(); err != nil {
+				log.ErrorContext(ctx, "failed to close ai handler", "error", err)
+			}
+		}()
 	}
 
-	db, err := db.NewDB(b.dbPath)
+	storage, err := b.openStorage(ctx)
 	if err != nil {
 		return fmt.Errorf("open db connection: %w", err)
 	}
+	if config.StatsFlushInterval > 0 {
+		storage = db.NewBufferedStorage(storage, config.StatsFlushInterval)
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.ErrorContext(ctx, "failed to close db connection", "error", err)
+		}
+	}()
 
 	self, err := b.api.GetMe()
 	if err != nil {
 		return fmt.Errorf("get self: %w", err)
 	}
 
+	botMention := botMentionPattern(self.Username)
 	stickerSetG := &singleflight.Group{}
+	windowsMu := &sync.Mutex{}
+	aiJobs := newAIJobPool(config.AIJobs.Concurrency, config.AIJobs.QueueSize)
+
+	backuper := newBackuper(b.config, storage)
+	go backuper.Run(ctx)
 
 	wg := sync.WaitGroup{}
 	wg.Add(b.workerCount)
@@ -85,13 +118,19 @@ func (b *Bot) Run(ctx context.Context) error {
 		go func() {
 			defer wg.Done()
 			w := worker{
-				config:         b.config,
+				state:          &b.state,
+				rng:            rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
 				api:            b.api,
+				botID:          self.ID,
 				botUsername:    self.Username,
+				botMention:     botMention,
 				getStickerSetG: stickerSetG,
 				cache:          cache,
-				db:             db,
+				windowsMu:      windowsMu,
+				db:             storage,
+				backuper:       backuper,
 				ai:             aiHandler,
+				aiJobs:         aiJobs,
 				log:            logging.New(fmt.Sprintf("worker-%d", workerId)),
 				updates:        workerUpdatesChan,
 			}
@@ -107,10 +146,14 @@ func (b *Bot) Run(ctx context.Context) error {
 		return fmt.Errorf("subscribe to updates: %w", err)
 	}
 
-	if b.config.Metrics != nil && b.config.Metrics.Addr != "" {
+	if config.Metrics != nil && config.Metrics.Addr != "" {
 		b.runMetricsServer(ctx)
 	}
 
+	if b.configPath != "" {
+		b.watchConfig(ctx, aiHandler, cache)
+	}
+
 	log.InfoContext(ctx, "listening for updates from bot", "username", self.Username)
 	skipQueuedUpdates(ctx, log, newUpdatesChan)
 
@@ -130,6 +173,10 @@ loop:
 	log.InfoContext(ctx, "waiting for workers to shut down")
 	wg.Wait()
 	log.InfoContext(ctx, "all workers stopped")
+
+	log.InfoContext(ctx, "waiting for ai jobs to finish")
+	aiJobs.wait()
+	log.InfoContext(ctx, "all ai jobs finished")
 	return nil
 }
 
@@ -174,6 +221,14 @@ func WithCacheCleanupInterval(duration time.Duration) Option {
 	}
 }
 
+// WithConfigPath enables reloading config from the given path on SIGHUP
+// and whenever the file changes.
+func WithConfigPath(configPath string) Option {
+	return func(b *Bot) {
+		b.configPath = configPath
+	}
+}
+
 func WithDBPath(dbPath string) Option {
 	return func(b *Bot) {
 		b.dbPath = dbPath
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/logging"
+)
+
+type BroadcastResult struct {
+	Total   int
+	Success int
+	Failure int
+}
+
+// Broadcast sends text to every chat the bot has collected stats for.
+func (b *Bot) Broadcast(ctx context.Context, text string) (BroadcastResult, error) {
+	storage, err := b.openStorage(ctx)
+	if err != nil {
+		return BroadcastResult{}, fmt.Errorf("open db connection: %w", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	return broadcast(ctx, logging.New("broadcast"), b.api, storage, text, 0)
+}
+
+// broadcast sends text to every known chat except skipChatID. Failures to deliver to
+// individual chats are logged and counted rather than returned.
+func broadcast(
+	ctx context.Context,
+	log *slog.Logger,
+	api *telego.Bot,
+	storage db.Storage,
+	text string,
+	skipChatID int,
+) (result BroadcastResult, _ error) {
+	chats, err := storage.GetAllChats(ctx)
+	if err != nil {
+		return result, fmt.Errorf("get all chats: %w", err)
+	}
+	log.DebugContext(ctx, "broadcasting message to chats", "chats", chats)
+
+	errs := make([]error, 0)
+	for _, chatID := range chats {
+		if chatID == skipChatID {
+			continue
+		}
+		result.Total++
+
+		_, err := api.SendMessage(&telego.SendMessageParams{
+			ChatID: telego.ChatID{ID: int64(chatID)},
+			Text:   text,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("send message to chat %d: %w", chatID, err))
+			result.Failure++
+		} else {
+			result.Success++
+		}
+	}
+	if sendErr := errors.Join(errs...); sendErr != nil {
+		log.WarnContext(ctx, "failed to send message to some chats", "error", sendErr)
+	}
+
+	return result, nil
+}
@@ -1,14 +1,19 @@
 package bot
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
 
 	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
 )
 
 type Command struct {
@@ -18,25 +23,56 @@ type Command struct {
 }
 
 func (c *Command) Called(msg *telego.Message, username string) bool {
-	return msg.Text == fmt.Sprintf("/%s@%s", c.Name, username) ||
-		msg.Text == fmt.Sprintf("/%s", c.Name)
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return false
+	}
+	return fields[0] == fmt.Sprintf("/%s@%s", c.Name, username) ||
+		fields[0] == fmt.Sprintf("/%s", c.Name)
+}
+
+// commandArgs returns whitespace-separated arguments that follow the command name.
+func commandArgs(msg *telego.Message) []string {
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields[1:]
 }
 
 func (w *worker) RunCommand(ctx context.Context, cmd Command, msg *telego.Message) error {
-	if cmd.AdminOnly && !w.config.IsAdmin(msg.Chat.ID) {
+	if cmd.AdminOnly && !w.config().IsAdmin(msg.Chat.ID) {
 		w.log.DebugContext(ctx, "user tried to execute admin command", "command", cmd.Name)
 
-		response := simpleReply("You are not authorized to use this command", msg)
-		_, err := w.api.SendMessage(response)
-		if err != nil {
-			return fmt.Errorf("send message: %w", err)
-		}
-		return nil
+		return w.reply(ctx, msg, texts.Unauthorized, nil)
 	}
 
 	return cmd.Handler(ctx, msg)
 }
 
+// canManageChat reports whether the sender may change settings of the chat: bot admins
+// and chat administrators can do it anywhere, and everyone can do it in private chats.
+func (w *worker) canManageChat(msg *telego.Message) (bool, error) {
+	if msg.Chat.Type == telego.ChatTypePrivate || w.config().IsAdmin(msg.From.ID) {
+		return true, nil
+	}
+
+	member, err := w.api.GetChatMember(&telego.GetChatMemberParams{
+		ChatID: msg.Chat.ChatID(),
+		UserID: msg.From.ID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("get chat member: %w", err)
+	}
+
+	switch member.MemberStatus() {
+	case telego.MemberStatusCreator, telego.MemberStatusAdministrator:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
 func (w *worker) handleStatsRequest(ctx context.Context, msg *telego.Message) error {
 	stats, err := w.db.RetrieveStats(ctx, int(msg.Chat.ID))
 	if err != nil {
@@ -45,13 +81,18 @@ func (w *worker) handleStatsRequest(ctx context.Context, msg *telego.Message) er
 
 	responseLines := make([]string, 0, len(stats))
 	for _, stat := range stats {
-		line := fmtStatsLine(stat)
+		line := w.render(ctx, msg, texts.StatsLine, texts.Stats{
+			Name:         stat.UserDisplayName,
+			Svo:          stat.SvoCount,
+			Zov:          stat.ZovCount,
+			Likvidirovan: stat.LikvidirovanCount,
+		})
 		responseLines = append(responseLines, line)
 	}
 	responseText := strings.TrimSpace(strings.Join(responseLines, "\n\n"))
 
 	if responseText == "" {
-		responseText = "Для этого чата не было собрано никакой статистики :("
+		responseText = w.render(ctx, msg, texts.StatsEmpty, nil)
 	}
 
 	response := simpleReply(responseText, msg)
@@ -63,115 +104,94 @@ func (w *worker) handleStatsRequest(ctx context.Context, msg *telego.Message) er
 	return nil
 }
 
-func fmtStatsLine(stat db.NamedStats) string {
-	svoStr := "СВО"
-
-	zovStr := func() string {
-		switch stat.ZovCount % 10 {
-		case 1:
-			return "ЗОВ"
-		case 2, 3, 4:
-			return "ЗОВ-а"
-		default:
-			return "ЗОВ-ов"
-		}
-	}()
-
-	likvidirovanStr := func() string {
-		switch stat.LikvidirovanCount {
-		case 1:
-			return "ЛИКВИДАЦИЮ"
-		case 2, 3, 4:
-			return "ЛИКВИДАЦИИ"
-		default:
-			return "ЛИКВИДАЦИЙ"
-		}
-	}()
-
-	return fmt.Sprintf(
-		"%s: %d %s и %d %s повлекли за собой %d %s",
-		stat.UserDisplayName,
-		stat.SvoCount, svoStr,
-		stat.ZovCount, zovStr,
-		stat.LikvidirovanCount, likvidirovanStr,
-	)
+func (w *worker) handlePwdRequest(ctx context.Context, msg *telego.Message) error {
+	return w.reply(ctx, msg, texts.Pwd, map[string]any{
+		"ChatID":  msg.Chat.ID,
+		"IsAdmin": w.config().IsAdmin(msg.Chat.ID),
+	})
 }
 
-func (w *worker) handlePwdRequest(ctx context.Context, msg *telego.Message) error {
-	text := fmt.Sprintf("chat_id: %d", msg.Chat.ID)
-	if w.config.IsAdmin(msg.Chat.ID) {
-		text += "\nis_admin: true"
+func (w *worker) handleBroadcastRequest(ctx context.Context, msg *telego.Message) error {
+	if msg.ReplyToMessage == nil {
+		return w.reply(ctx, msg, texts.BroadcastNoReply, nil)
 	}
 
-	response := simpleReply(text, msg)
-	_, err := w.api.SendMessage(response)
+	broadcastText := msg.ReplyToMessage.Text
+	if broadcastText == "" {
+		return w.reply(ctx, msg, texts.BroadcastNoText, nil)
+	}
+
+	w.log.DebugContext(ctx, "broadcasting message to chats", "message", broadcastText)
+	result, err := broadcast(ctx, w.log, w.api, w.db, broadcastText, int(msg.Chat.ID))
 	if err != nil {
-		return fmt.Errorf("send message: %w", err)
+		return fmt.Errorf("broadcast: %w", err)
 	}
 
-	return nil
+	return w.reply(ctx, msg, texts.BroadcastResult, map[string]any{
+		"Total":   result.Total,
+		"Success": result.Success,
+		"Failure": result.Failure,
+	})
 }
 
-func (w *worker) handleBroadcastRequest(ctx context.Context, msg *telego.Message) error {
-	if msg.ReplyToMessage == nil {
-		response := simpleReply("Please reply to a message to broadcast it", msg)
-		_, err := w.api.SendMessage(response)
+func (w *worker) handleExportStatsRequest(ctx context.Context, msg *telego.Message) error {
+	format := db.FormatCSV
+	chatID := 0
+
+	args := commandArgs(msg)
+	if len(args) > 2 {
+		return w.reply(ctx, msg, texts.ExportUsage, nil)
+	}
+	if len(args) >= 1 {
+		var err error
+		format, err = db.ParseExportFormat(args[0])
 		if err != nil {
-			return fmt.Errorf("send message: %w", err)
+			return w.reply(ctx, msg, texts.ExportInvalidFormat, map[string]any{"Format": args[0]})
 		}
-		return nil
 	}
-
-	broadcastText := msg.ReplyToMessage.Text
-	if broadcastText == "" {
-		response := simpleReply("Please reply to a message with text to broadcast it", msg)
-		_, err := w.api.SendMessage(response)
+	if len(args) == 2 {
+		var err error
+		chatID, err = strconv.Atoi(args[1])
 		if err != nil {
-			return fmt.Errorf("send message: %w", err)
+			return w.reply(ctx, msg, texts.ExportInvalidChatID, map[string]any{"ChatID": args[1]})
 		}
-		return nil
 	}
 
-	chats, err := w.db.GetAllChats(ctx)
+	stats, err := w.db.ExportStats(ctx, chatID)
 	if err != nil {
-		return fmt.Errorf("get all chats: %w", err)
+		return fmt.Errorf("export stats: %w", err)
 	}
-	w.log.DebugContext(ctx, "broadcasting message to chats", "chats", chats, "message", msg.ReplyToMessage.Text)
-
-	errs := make([]error, 0)
-	successCount := 0
-	failureCount := 0
-	for _, chatID := range chats {
-		if chatID == int(msg.Chat.ID) {
-			continue
-		}
 
-		_, err := w.api.SendMessage(&telego.SendMessageParams{
-			ChatID: telego.ChatID{ID: int64(chatID)},
-			Text:   broadcastText,
-		})
-		if err != nil {
-			errs = append(errs, fmt.Errorf("send message to chat %d: %w", chatID, err))
-			failureCount++
-		} else {
-			successCount++
-		}
-	}
-	if sendErr := errors.Join(errs...); sendErr != nil {
-		w.log.WarnContext(ctx, "failed to send message to some chats", "error", sendErr)
+	buf := bytes.NewBuffer(nil)
+	if err := db.WriteStats(buf, format, stats); err != nil {
+		return fmt.Errorf("write stats: %w", err)
 	}
 
-	response := simpleReply(
-		fmt.Sprintf(
-			"Finished broadcasting to %d chats: %d success, %d failure",
-			len(chats)-1, successCount, failureCount,
-		),
-		msg,
-	)
-	_, err = w.api.SendMessage(response)
+	fileName := fmt.Sprintf("svoi-stats-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	_, err = w.api.SendDocument(&telego.SendDocumentParams{
+		ChatID:   msg.Chat.ChatID(),
+		Document: tu.File(tu.NameReader(buf, fileName)),
+		Caption:  w.render(ctx, msg, texts.ExportCaption, map[string]any{"Count": len(stats)}),
+		ReplyParameters: &telego.ReplyParameters{
+			MessageID: msg.MessageID,
+		},
+	})
 	if err != nil {
-		return fmt.Errorf("send message: %w", err)
+		return fmt.Errorf("send document: %w", err)
 	}
 
 	return nil
 }
+
+func (w *worker) handleBackupRequest(ctx context.Context, msg *telego.Message) error {
+	path, err := w.backuper.Backup(ctx)
+	switch {
+	case errors.Is(err, errBackupsDisabled):
+		return w.reply(ctx, msg, texts.BackupDisabled, nil)
+	case err != nil:
+		w.log.ErrorContext(ctx, "failed to make backup", "error", err)
+		return w.reply(ctx, msg, texts.BackupFailed, map[string]any{"Error": err.Error()})
+	default:
+		return w.reply(ctx, msg, texts.BackupDone, map[string]any{"Path": path})
+	}
+}
@@ -2,29 +2,158 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/sethvargo/go-envconfig"
 	"gopkg.in/yaml.v3"
 
 	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/detector"
+	"github.com/LeKSuS-04/svoi-bot/internal/strategy"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
 )
 
 type StickerSetConfig struct {
 	Name              string   `yaml:"name"`
 	ExcludeStickerIDs []string `yaml:"exclude_sticker_ids"`
+	// Weight of the set relative to other sets, 1 if not set. Sets with zero weight are never used.
+	Weight *int `yaml:"weight"`
+	// StickerWeights override weights of single stickers, identified by file ID or file unique ID.
+	// Stickers with zero weight are never sent.
+	StickerWeights map[string]int `yaml:"sticker_weights"`
 }
 
+// DefaultWeight is the weight of sticker sets and media collections without one.
+const DefaultWeight = 1
+
+func weightOrDefault(weight *int) int {
+	if weight == nil {
+		return DefaultWeight
+	}
+	return *weight
+}
+
+type StickersConfig struct {
+	// RefreshInterval is how long sticker sets are cached before being loaded again.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// AvoidRepeats is the number of last stickers sent to a chat that are not sent again,
+	// unless there is nothing else to choose from.
+	AvoidRepeats int `yaml:"avoid_repeats"`
+	// TriggerEmojis limit stickers sent for a trigger type to the ones with these emojis.
+	// If no sticker of the chosen set matches, any sticker of the set can be sent.
+	TriggerEmojis map[detector.Type][]string `yaml:"trigger_emojis"`
+	// Chats limit sticker sets used in specific chats to the listed names, either from
+	// sticker_sets or added with /addstickerset.
+	Chats map[int64][]string `yaml:"chats"`
+}
+
+// MediaConfig is a collection of media files of the same kind. A collection is picked
+// with probability proportional to its weight, and then a file is picked uniformly.
+type MediaConfig struct {
+	Name string        `yaml:"name"`
+	Kind strategy.Kind `yaml:"kind"`
+	// Weight is 1 if not set. Collections with zero weight are never used.
+	Weight *int `yaml:"weight"`
+	// FileIDs are files already uploaded to Telegram.
+	FileIDs []string `yaml:"file_ids"`
+	// Files and files in Dir are uploaded on first use, and their file IDs are stored in the database.
+	Files []string `yaml:"files"`
+	Dir   string   `yaml:"dir"`
+	// Exclude contains file IDs or names of files in Dir that should not be sent.
+	Exclude []string `yaml:"exclude"`
+}
+
+type StorageDriver string
+
+const (
+	StorageSqlite   StorageDriver = "sqlite"
+	StoragePostgres StorageDriver = "postgres"
+)
+
 type Config struct {
-	BotToken    string             `env:"BOT_TOKEN"`
-	SqlitePath  string             `yaml:"sqlite_path" env:"SQLITE_PATH"`
-	StickerSets []StickerSetConfig `yaml:"sticker_sets"`
-	AdminIDs    []int64            `yaml:"admin_ids"`
-	AI          *ai.Config         `yaml:"ai"`
-	Metrics     *MetricsConfig     `yaml:"metrics"`
+	BotToken           string             `env:"BOT_TOKEN"`
+	StorageDriver      StorageDriver      `yaml:"storage_driver" env:"STORAGE_DRIVER"`
+	SqlitePath         string             `yaml:"sqlite_path" env:"SQLITE_PATH"`
+	PostgresDSN        string             `yaml:"postgres_dsn" env:"POSTGRES_DSN"`
+	StatsFlushInterval time.Duration      `yaml:"stats_flush_interval"`
+	Triggers           detector.Config    `yaml:"triggers"`
+	Responses          strategy.Config    `yaml:"responses"`
+	Texts              texts.Config       `yaml:"texts"`
+	StickerSets        []StickerSetConfig `yaml:"sticker_sets"`
+	Stickers           StickersConfig     `yaml:"stickers"`
+	Media              []MediaConfig      `yaml:"media"`
+	Spam               SpamConfig         `yaml:"spam"`
+	AdminIDs           []int64            `yaml:"admin_ids"`
+	AI                 *ai.Config         `yaml:"ai"`
+	AIJobs             AIJobsConfig       `yaml:"ai_jobs"`
+	AIReplies          AIRepliesConfig    `yaml:"ai_replies"`
+	Metrics            *MetricsConfig     `yaml:"metrics"`
+	Backup             *BackupConfig      `yaml:"backup"`
+}
+
+type SpamSensitivity string
+
+const (
+	SpamSensitivityOff    SpamSensitivity = "off"
+	SpamSensitivityLow    SpamSensitivity = "low"
+	SpamSensitivityNormal SpamSensitivity = "normal"
+	SpamSensitivityHigh   SpamSensitivity = "high"
+)
+
+var SpamSensitivities = []SpamSensitivity{SpamSensitivityOff, SpamSensitivityLow, SpamSensitivityNormal, SpamSensitivityHigh}
+
+// SpamConfig configures flood control. Messages with triggers are counted per user and per chat
+// over a sliding window; going over a limit makes the message spam, and so does a single message
+// with too many triggers. Spam is never answered and never counted in stats. The first spam
+// messages of a user within the window are ignored silently, the WarnAfter-th one gets a single
+// warning, and after MuteAfter the bot ignores the user for MuteDuration.
+type SpamConfig struct {
+	Window    time.Duration `yaml:"window"`
+	UserLimit int           `yaml:"user_limit"`
+	ChatLimit int           `yaml:"chat_limit"`
+
+	WarnAfter    int           `yaml:"warn_after"`
+	MuteAfter    int           `yaml:"mute_after"`
+	MuteDuration time.Duration `yaml:"mute_duration"`
+
+	// Sensitivity scales the limits: low doubles them, high halves them, and off disables
+	// spam detection. Chat admins can override it for their chat with /spam.
+	Sensitivity SpamSensitivity           `yaml:"sensitivity"`
+	Chats       map[int64]SpamSensitivity `yaml:"chats"`
+}
+
+// AIJobsConfig configures the pool AI responses are generated in, so that workers don't
+// wait for them. Jobs that don't fit in the queue, and jobs that miss the deadline, are
+// answered with a default response, or with a neutral error text for AI replies.
+type AIJobsConfig struct {
+	Concurrency int           `yaml:"concurrency"`
+	QueueSize   int           `yaml:"queue_size"`
+	Deadline    time.Duration `yaml:"deadline"`
+}
+
+type AIReplySource string
+
+const (
+	AIReplyMention AIReplySource = "mention"
+	AIReplyReply   AIReplySource = "reply"
+	AIReplyPrivate AIReplySource = "private"
+)
+
+var AIReplySources = []AIReplySource{AIReplyMention, AIReplyReply, AIReplyPrivate}
+
+// AIRepliesConfig configures AI replies to messages addressed to the bot, which have no triggers:
+// mentions of the bot, replies to its messages and messages in private chats. Chat admins can turn
+// them on or off for their chat with /aireplies. Replies share the cooldown with AI responses
+// to triggers, and remember up to HistoryLength messages of the conversation.
+type AIRepliesConfig struct {
+	Enabled       bool            `yaml:"enabled"`
+	Sources       []AIReplySource `yaml:"sources"`
+	HistoryLength int             `yaml:"history_length"`
 }
 
 type MetricsConfig struct {
@@ -32,6 +161,19 @@ type MetricsConfig struct {
 	UpdatePeriod time.Duration `yaml:"update_period"`
 }
 
+type BackupConfig struct {
+	Dir      string        `yaml:"dir"`
+	Interval time.Duration `yaml:"interval"`
+	KeepLast int           `yaml:"keep_last"`
+	MaxAge   time.Duration `yaml:"max_age"`
+}
+
+// Enabled reports whether backups can be made. Scheduled backups additionally require
+// a positive interval, otherwise backups are only made on admin request.
+func (c *BackupConfig) Enabled() bool {
+	return c != nil && c.Dir != ""
+}
+
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -47,9 +189,288 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("process envconfig: %w", err)
 	}
 
+	config.SetDefaults()
 	return config, nil
 }
 
+const (
+	DefaultMetricsUpdatePeriod     = 15 * time.Second
+	DefaultBackupKeepLast          = 7
+	DefaultStickersRefreshInterval = 24 * time.Hour
+	DefaultSpamWindow              = time.Minute
+	DefaultSpamUserLimit           = 6
+	DefaultSpamChatLimit           = 30
+	DefaultSpamWarnAfter           = 2
+	DefaultSpamMuteAfter           = 4
+	DefaultSpamMuteDuration        = 10 * time.Minute
+	DefaultAIJobsConcurrency       = 4
+	DefaultAIJobsQueueSize         = 32
+	DefaultAIJobsDeadline          = 45 * time.Second
+	DefaultAIRepliesHistoryLength  = 10
+)
+
+func (c *Config) SetDefaults() {
+	if c.StorageDriver == "" {
+		c.StorageDriver = StorageSqlite
+	}
+
+	if c.AI == nil {
+		c.AI = &ai.Config{}
+	}
+	c.AI.SetDefaults()
+	if c.AIJobs.Concurrency == 0 {
+		c.AIJobs.Concurrency = DefaultAIJobsConcurrency
+	}
+	if c.AIJobs.QueueSize == 0 {
+		c.AIJobs.QueueSize = DefaultAIJobsQueueSize
+	}
+	if c.AIJobs.Deadline == 0 {
+		c.AIJobs.Deadline = DefaultAIJobsDeadline
+	}
+	if c.AIReplies.Sources == nil {
+		c.AIReplies.Sources = AIReplySources
+	}
+	if c.AIReplies.HistoryLength == 0 {
+		c.AIReplies.HistoryLength = DefaultAIRepliesHistoryLength
+	}
+
+	if c.Stickers.RefreshInterval == 0 {
+		c.Stickers.RefreshInterval = DefaultStickersRefreshInterval
+	}
+
+	if c.Spam.Window == 0 {
+		c.Spam.Window = DefaultSpamWindow
+	}
+	if c.Spam.UserLimit == 0 {
+		c.Spam.UserLimit = DefaultSpamUserLimit
+	}
+	if c.Spam.ChatLimit == 0 {
+		c.Spam.ChatLimit = DefaultSpamChatLimit
+	}
+	if c.Spam.WarnAfter == 0 {
+		c.Spam.WarnAfter = DefaultSpamWarnAfter
+	}
+	if c.Spam.MuteAfter == 0 {
+		c.Spam.MuteAfter = DefaultSpamMuteAfter
+	}
+	if c.Spam.MuteDuration == 0 {
+		c.Spam.MuteDuration = DefaultSpamMuteDuration
+	}
+	if c.Spam.Sensitivity == "" {
+		c.Spam.Sensitivity = SpamSensitivityNormal
+	}
+
+	if c.Metrics != nil && c.Metrics.UpdatePeriod == 0 {
+		c.Metrics.UpdatePeriod = DefaultMetricsUpdatePeriod
+	}
+
+	if c.Backup != nil && c.Backup.KeepLast == 0 && c.Backup.MaxAge == 0 {
+		c.Backup.KeepLast = DefaultBackupKeepLast
+	}
+}
+
 func (c *Config) IsAdmin(id int64) bool {
 	return slices.Contains(c.AdminIDs, id)
 }
+
+func (c *Config) hasStickerSet(name string) bool {
+	return slices.ContainsFunc(c.StickerSets, func(stickerSet StickerSetConfig) bool {
+		return stickerSet.Name == name
+	})
+}
+
+// Validate reports all problems found in the config at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.BotToken == "" {
+		errs = append(errs, errors.New("bot token must be set via BOT_TOKEN environment variable"))
+	}
+
+	switch c.StorageDriver {
+	case StorageSqlite:
+	case StoragePostgres:
+		if c.PostgresDSN == "" {
+			errs = append(errs, errors.New("postgres_dsn must be set when using postgres storage"))
+		}
+		if c.Backup.Enabled() {
+			errs = append(errs, errors.New("backup is only supported for sqlite storage"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage_driver must be %q or %q, got %q", StorageSqlite, StoragePostgres, c.StorageDriver))
+	}
+
+	if c.StatsFlushInterval < 0 {
+		errs = append(errs, fmt.Errorf("stats_flush_interval must not be negative, got %s", c.StatsFlushInterval))
+	}
+
+	for i, entry := range c.Triggers.Whitelist {
+		if strings.TrimSpace(entry) == "" {
+			errs = append(errs, fmt.Errorf("triggers.whitelist[%d] must not be empty", i))
+		}
+	}
+
+	if err := c.Responses.Validate(); err != nil {
+		errs = append(errs, prefixErrors("responses", err))
+	}
+	for path, text := range c.Responses.Texts() {
+		if _, err := texts.Parse(c.Texts.DefaultLanguage, text); err != nil {
+			errs = append(errs, fmt.Errorf("responses.%s: %w", path, err))
+		}
+	}
+
+	if err := c.Texts.Validate(); err != nil {
+		errs = append(errs, prefixErrors("texts", err))
+	}
+
+	seenStickerSets := make(map[string]bool, len(c.StickerSets))
+	for i, stickerSet := range c.StickerSets {
+		if stickerSet.Name == "" {
+			errs = append(errs, fmt.Errorf("sticker_sets[%d].name must not be empty", i))
+			continue
+		}
+		if seenStickerSets[stickerSet.Name] {
+			errs = append(errs, fmt.Errorf("sticker_sets[%d]: duplicate sticker set %q", i, stickerSet.Name))
+		}
+		seenStickerSets[stickerSet.Name] = true
+
+		if stickerSet.Weight != nil && *stickerSet.Weight < 0 {
+			errs = append(errs, fmt.Errorf("sticker_sets[%d].weight must not be negative, got %d", i, *stickerSet.Weight))
+		}
+		for id, weight := range stickerSet.StickerWeights {
+			if weight < 0 {
+				errs = append(errs, fmt.Errorf("sticker_sets[%d].sticker_weights.%s must not be negative, got %d", i, id, weight))
+			}
+		}
+	}
+
+	if c.Stickers.RefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("stickers.refresh_interval must not be negative, got %s", c.Stickers.RefreshInterval))
+	}
+	if c.Stickers.AvoidRepeats < 0 {
+		errs = append(errs, fmt.Errorf("stickers.avoid_repeats must not be negative, got %d", c.Stickers.AvoidRepeats))
+	}
+	for triggerType := range c.Stickers.TriggerEmojis {
+		if triggerType != detector.Svo && triggerType != detector.Zov {
+			errs = append(errs, fmt.Errorf("stickers.trigger_emojis: unknown trigger type %q", triggerType))
+		}
+	}
+	for chatID, names := range c.Stickers.Chats {
+		if len(names) == 0 {
+			errs = append(errs, fmt.Errorf("stickers.chats.%d must not be empty", chatID))
+		}
+	}
+
+	seenMedia := make(map[string]bool, len(c.Media))
+	for i, media := range c.Media {
+		if media.Name == "" {
+			errs = append(errs, fmt.Errorf("media[%d].name must not be empty", i))
+		} else if seenMedia[media.Name] {
+			errs = append(errs, fmt.Errorf("media[%d]: duplicate media collection %q", i, media.Name))
+		}
+		seenMedia[media.Name] = true
+
+		if !slices.Contains(strategy.MediaKinds, media.Kind) {
+			errs = append(errs, fmt.Errorf("media[%d].kind must be one of %v, got %q", i, strategy.MediaKinds, media.Kind))
+		}
+		if media.Weight != nil && *media.Weight < 0 {
+			errs = append(errs, fmt.Errorf("media[%d].weight must not be negative, got %d", i, *media.Weight))
+		}
+		if len(media.FileIDs) == 0 && len(media.Files) == 0 && media.Dir == "" {
+			errs = append(errs, fmt.Errorf("media[%d]: at least one of file_ids, files and dir must be set", i))
+		}
+	}
+	for _, kind := range strategy.MediaKinds {
+		if !c.Responses.UsesKind(kind) {
+			continue
+		}
+		if !slices.ContainsFunc(c.Media, func(media MediaConfig) bool { return media.Kind == kind }) {
+			errs = append(errs, fmt.Errorf("responses use %q kind, but there is no media of this kind", kind))
+		}
+	}
+
+	if c.Spam.Window < 0 {
+		errs = append(errs, fmt.Errorf("spam.window must be positive, got %s", c.Spam.Window))
+	}
+	if c.Spam.UserLimit < 0 {
+		errs = append(errs, fmt.Errorf("spam.user_limit must be positive, got %d", c.Spam.UserLimit))
+	}
+	if c.Spam.ChatLimit < 0 {
+		errs = append(errs, fmt.Errorf("spam.chat_limit must be positive, got %d", c.Spam.ChatLimit))
+	}
+	if c.Spam.WarnAfter < 0 {
+		errs = append(errs, fmt.Errorf("spam.warn_after must be positive, got %d", c.Spam.WarnAfter))
+	}
+	if c.Spam.MuteAfter < c.Spam.WarnAfter {
+		errs = append(errs, fmt.Errorf("spam.mute_after must not be less than spam.warn_after, got %d", c.Spam.MuteAfter))
+	}
+	if c.Spam.MuteDuration < 0 {
+		errs = append(errs, fmt.Errorf("spam.mute_duration must be positive, got %s", c.Spam.MuteDuration))
+	}
+	if !slices.Contains(SpamSensitivities, c.Spam.Sensitivity) {
+		errs = append(errs, fmt.Errorf("spam.sensitivity must be one of %v, got %q", SpamSensitivities, c.Spam.Sensitivity))
+	}
+	for chatID, sensitivity := range c.Spam.Chats {
+		if !slices.Contains(SpamSensitivities, sensitivity) {
+			errs = append(errs, fmt.Errorf("spam.chats.%d must be one of %v, got %q", chatID, SpamSensitivities, sensitivity))
+		}
+	}
+
+	if c.Metrics != nil && c.Metrics.Addr != "" && c.Metrics.UpdatePeriod <= 0 {
+		errs = append(errs, fmt.Errorf("metrics.update_period must be positive, got %s", c.Metrics.UpdatePeriod))
+	}
+
+	if c.Backup != nil {
+		if c.Backup.Interval < 0 {
+			errs = append(errs, fmt.Errorf("backup.interval must not be negative, got %s", c.Backup.Interval))
+		}
+		if c.Backup.Interval > 0 && c.Backup.Dir == "" {
+			errs = append(errs, errors.New("backup.dir must be set for scheduled backups"))
+		}
+		if c.Backup.KeepLast < 0 {
+			errs = append(errs, fmt.Errorf("backup.keep_last must not be negative, got %d", c.Backup.KeepLast))
+		}
+		if c.Backup.MaxAge < 0 {
+			errs = append(errs, fmt.Errorf("backup.max_age must not be negative, got %s", c.Backup.MaxAge))
+		}
+	}
+
+	if c.AI == nil {
+		errs = append(errs, errors.New("ai section is required"))
+	} else if err := c.AI.Validate(); err != nil {
+		errs = append(errs, prefixErrors("ai", err))
+	}
+	if c.AIJobs.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("ai_jobs.concurrency must be positive, got %d", c.AIJobs.Concurrency))
+	}
+	if c.AIJobs.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("ai_jobs.queue_size must not be negative, got %d", c.AIJobs.QueueSize))
+	}
+	if c.AIJobs.Deadline < 0 {
+		errs = append(errs, fmt.Errorf("ai_jobs.deadline must be positive, got %s", c.AIJobs.Deadline))
+	}
+	for i, source := range c.AIReplies.Sources {
+		if !slices.Contains(AIReplySources, source) {
+			errs = append(errs, fmt.Errorf("ai_replies.sources[%d] must be one of %v, got %q", i, AIReplySources, source))
+		}
+	}
+	if c.AIReplies.HistoryLength < 0 {
+		errs = append(errs, fmt.Errorf("ai_replies.history_length must be positive, got %d", c.AIReplies.HistoryLength))
+	}
+
+	return errors.Join(errs...)
+}
+
+// prefixErrors prefixes every error joined in err with the name of the config section.
+func prefixErrors(section string, err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return fmt.Errorf("%s.%w", section, err)
+	}
+
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, fmt.Errorf("%s.%w", section, err))
+	}
+	return errors.Join(errs...)
+}
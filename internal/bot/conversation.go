@@ -0,0 +1,200 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
+)
+
+// settingAIReplies is the chat setting that turns AI replies on or off for the chat.
+const settingAIReplies = "ai_replies"
+
+func chatAIRepliesCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat_ai_replies:%d", chatID)
+}
+
+func aiConversationCacheKey(chatID int64, messageID int) string {
+	return fmt.Sprintf("ai_conversation:%d:%d", chatID, messageID)
+}
+
+// aiRepliesEnabled reports whether AI replies are turned on for the chat.
+func (w *worker) aiRepliesEnabled(ctx context.Context, chatID int64) bool {
+	key := chatAIRepliesCacheKey(chatID)
+	if value, ok := w.cache.Get(key); ok {
+		return value.(bool)
+	}
+
+	enabled := w.config().AIReplies.Enabled
+	value, ok, err := w.db.GetChatSetting(ctx, int(chatID), settingAIReplies)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to get chat ai replies setting", "error", err)
+		return enabled
+	}
+	if ok {
+		enabled = value == "on"
+	}
+	w.cache.SetDefault(key, enabled)
+	return enabled
+}
+
+// aiReplySource returns why msg is addressed to the bot, or empty string if it is not.
+func (w *worker) aiReplySource(msg *telego.Message) AIReplySource {
+	switch {
+	case msg.Chat.Type == telego.ChatTypePrivate:
+		return AIReplyPrivate
+	case msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.ID == w.botID:
+		return AIReplyReply
+	case w.mentionsBot(msg):
+		return AIReplyMention
+	default:
+		return ""
+	}
+}
+
+func (w *worker) mentionsBot(msg *telego.Message) bool {
+	body, entities := messageText(msg)
+	var text []uint16
+	for _, entity := range entities {
+		switch entity.Type {
+		case telego.EntityTypeTextMention:
+			if entity.User != nil && entity.User.ID == w.botID {
+				return true
+			}
+		case telego.EntityTypeMention:
+			// Offsets of entities are in UTF-16 code units.
+			if text == nil {
+				text = utf16.Encode([]rune(body))
+			}
+			if entity.Offset+entity.Length > len(text) {
+				continue
+			}
+			mention := string(utf16.Decode(text[entity.Offset : entity.Offset+entity.Length]))
+			if strings.EqualFold(mention, "@"+w.botUsername) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// botMentionPattern matches mentions of the bot in the text, which are removed from prompts.
+func botMentionPattern(botUsername string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(botUsername) + `\b`)
+}
+
+// handleAIReply answers a message without triggers that is addressed to the bot, continuing
+// the conversation it replies to.
+func (w *worker) handleAIReply(ctx context.Context, msg *telego.Message, source AIReplySource) error {
+	if w.ai == nil || !slices.Contains(w.config().AIReplies.Sources, source) || !w.aiRepliesEnabled(ctx, msg.Chat.ID) {
+		return nil
+	}
+	if _, muted := w.cache.Get(mutedUserCacheKey(msg.Chat.ID, msg.From.ID)); muted {
+		w.log.DebugContext(ctx, "ignoring message of muted user")
+		return nil
+	}
+
+	text, _ := messageText(msg)
+	prompt := strings.TrimSpace(w.botMention.ReplaceAllString(text, ""))
+	// The persona is picked now, so that the image is only downloaded if its model accepts it.
+	persona := w.config().AI.PickPersona(w.persona(ctx, msg.Chat.ID))
+	imageFileID := w.aiImageFileID(msg, persona)
+	if prompt == "" && imageFileID == "" {
+		return nil
+	}
+
+	// Add fails if the user is on cooldown.
+	if err := w.cache.Add(aiSenderKey(msg.From.ID), struct{}{}, w.config().AI.ResponseResetPeriod); err != nil {
+		w.log.DebugContext(ctx, "ai reply is on cooldown", "source", source)
+		return nil
+	}
+	w.log.InfoContext(ctx, "generating ai reply", "source", source, "text", prompt)
+
+	pending := &aiResponse{
+		triggerResponseBase: triggerResponseBase{
+			typ: aiGenerated,
+		},
+		persona:     persona,
+		prompt:      prompt,
+		userContext: makeAIContext(msg),
+		history:     w.conversationHistory(msg),
+		imageFileID: imageFileID,
+		source:      source,
+	}
+	return w.sendReplies(ctx, msg, []reply{{response: pending}})
+}
+
+// conversationHistory returns earlier turns of the conversation msg continues: the remembered
+// conversation of the message it replies to, or just that message.
+func (w *worker) conversationHistory(msg *telego.Message) []ai.Turn {
+	replyTo := msg.ReplyToMessage
+	if replyTo == nil {
+		return nil
+	}
+	if history, ok := w.cache.Get(aiConversationCacheKey(msg.Chat.ID, replyTo.MessageID)); ok {
+		return history.([]ai.Turn)
+	}
+	text, _ := messageText(replyTo)
+	if replyTo.From == nil || text == "" {
+		return nil
+	}
+	return []ai.Turn{{
+		FromBot: replyTo.From.ID == w.botID,
+		User:    makeAIContext(replyTo),
+		Text:    text,
+	}}
+}
+
+// rememberConversation keeps the last turns of the conversation, so that replies to the message
+// continue it.
+func (w *worker) rememberConversation(chatID int64, messageID int, history []ai.Turn) {
+	if limit := w.config().AIReplies.HistoryLength; len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	w.cache.SetDefault(aiConversationCacheKey(chatID, messageID), history)
+}
+
+func (w *worker) handleAIRepliesRequest(ctx context.Context, msg *telego.Message) error {
+	args := commandArgs(msg)
+	if len(args) == 0 {
+		return w.reply(ctx, msg, texts.AIRepliesCurrent, map[string]any{
+			"Enabled": w.aiRepliesEnabled(ctx, msg.Chat.ID),
+		})
+	}
+
+	allowed, err := w.canManageChat(msg)
+	if err != nil {
+		return fmt.Errorf("check permissions: %w", err)
+	}
+	if !allowed {
+		return w.reply(ctx, msg, texts.NotChatAdmin, nil)
+	}
+
+	switch value := strings.ToLower(args[0]); value {
+	case "reset":
+		if err := w.db.DeleteChatSetting(ctx, int(msg.Chat.ID), settingAIReplies); err != nil {
+			return fmt.Errorf("delete chat ai replies setting: %w", err)
+		}
+		w.cache.Delete(chatAIRepliesCacheKey(msg.Chat.ID))
+		return w.reply(ctx, msg, texts.AIRepliesReset, map[string]any{
+			"Enabled": w.aiRepliesEnabled(ctx, msg.Chat.ID),
+		})
+
+	case "on", "off":
+		if err := w.db.SetChatSetting(ctx, int(msg.Chat.ID), settingAIReplies, value); err != nil {
+			return fmt.Errorf("set chat ai replies setting: %w", err)
+		}
+		w.cache.SetDefault(chatAIRepliesCacheKey(msg.Chat.ID), value == "on")
+		return w.reply(ctx, msg, texts.AIRepliesSet, map[string]any{"Enabled": value == "on"})
+
+	default:
+		return w.reply(ctx, msg, texts.AIRepliesUsage, nil)
+	}
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+)
+
+// messageText returns the text of the message along with its entities, or the caption
+// of media messages.
+func messageText(msg *telego.Message) (string, []telego.MessageEntity) {
+	if msg.Text == "" {
+		return msg.Caption, msg.CaptionEntities
+	}
+	return msg.Text, msg.Entities
+}
+
+// aiImageFileID returns the file ID of the image to pass to the AI along with msg: the photo
+// or the sticker thumbnail of the message, or of the message it replies to. Empty string
+// is returned if the model of the persona doesn't accept images or there is no image
+// within the size limit.
+func (w *worker) aiImageFileID(msg *telego.Message, persona string) string {
+	if !w.config().AI.AcceptsImages(persona) {
+		return ""
+	}
+	config := w.config().AI.Images
+
+	for _, m := range []*telego.Message{msg, msg.ReplyToMessage} {
+		if m == nil {
+			continue
+		}
+		if fileID := imageFileID(m, config.MaxSize); fileID != "" {
+			return fileID
+		}
+	}
+	return ""
+}
+
+func imageFileID(msg *telego.Message, maxSize int) string {
+	// Photo sizes go from the smallest one, so the largest one within the limit is picked.
+	for i := len(msg.Photo) - 1; i >= 0; i-- {
+		if msg.Photo[i].FileSize <= maxSize {
+			return msg.Photo[i].FileID
+		}
+	}
+	if msg.Sticker != nil && msg.Sticker.Thumbnail != nil && msg.Sticker.Thumbnail.FileSize <= maxSize {
+		return msg.Sticker.Thumbnail.FileID
+	}
+	return ""
+}
+
+// downloadImages downloads the image to pass to the AI. Generation goes on without it
+// if it can't be downloaded.
+func (w *worker) downloadImages(ctx context.Context, fileID string) []ai.Image {
+	if fileID == "" {
+		return nil
+	}
+
+	image, err := w.downloadImage(ctx, fileID, w.config().AI.Images.MaxSize)
+	if err != nil {
+		w.log.WarnContext(ctx, "failed to download image for ai", "fileId", fileID, "error", err)
+		return nil
+	}
+	w.log.DebugContext(ctx, "downloaded image for ai", "mediaType", image.MediaType, "size", len(image.Data))
+	return []ai.Image{image}
+}
+
+// downloadImage downloads the file within ctx, reading at most maxSize bytes of it, as
+// the size reported by Telegram is optional.
+func (w *worker) downloadImage(ctx context.Context, fileID string, maxSize int) (ai.Image, error) {
+	file, err := w.api.GetFile(&telego.GetFileParams{FileID: fileID})
+	if err != nil {
+		return ai.Image{}, fmt.Errorf("get file: %w", err)
+	}
+	if file.FileSize > int64(maxSize) {
+		return ai.Image{}, fmt.Errorf("file is too large: %d bytes", file.FileSize)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.api.FileDownloadURL(file.FilePath), nil)
+	if err != nil {
+		return ai.Image{}, fmt.Errorf("new request: %w", err)
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Errors of the client include the URL, which contains the bot token.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return ai.Image{}, fmt.Errorf("download file: %w", err)
+	}
+	defer func() { _ = rsp.Body.Close() }()
+
+	if rsp.StatusCode != http.StatusOK {
+		return ai.Image{}, fmt.Errorf("download file: unexpected status code: %d", rsp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(rsp.Body, int64(maxSize)+1))
+	if err != nil {
+		return ai.Image{}, fmt.Errorf("read file: %w", err)
+	}
+	if len(data) > maxSize {
+		return ai.Image{}, fmt.Errorf("file is larger than %d bytes", maxSize)
+	}
+
+	return ai.Image{
+		MediaType: http.DetectContentType(data),
+		Data:      data,
+	}, nil
+}
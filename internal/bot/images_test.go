@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+)
+
+// fakeFileServer serves getFile without a file size, as Telegram may do, and the file
+// itself with the handler.
+func fakeFileServer(t *testing.T, file http.HandlerFunc) *worker {
+	t.Helper()
+
+	api, _ := newFakeTelegram(t, map[string]http.HandlerFunc{
+		"getFile": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"file_id":"id","file_unique_id":"uid","file_path":"photos/file.jpg"}}`))
+		},
+		"file": file,
+	})
+	return &worker{api: api}
+}
+
+func TestDownloadImageLimitsSize(t *testing.T) {
+	const maxSize = 1024
+	for _, tc := range []struct {
+		name string
+		size int
+		ok   bool
+	}{
+		{name: "within limit", size: maxSize, ok: true},
+		{name: "over limit", size: maxSize + 1},
+		{name: "far over limit", size: 100 * maxSize},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := fakeFileServer(t, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(bytes.Repeat([]byte{0xff}, tc.size))
+			})
+
+			image, err := w.downloadImage(context.Background(), "id", maxSize)
+			if tc.ok {
+				if err != nil {
+					t.Fatalf("download image: %s", err)
+				}
+				if len(image.Data) != tc.size {
+					t.Errorf("got %d bytes, want %d", len(image.Data), tc.size)
+				}
+			} else if err == nil {
+				t.Errorf("downloaded %d bytes over the limit of %d", len(image.Data), maxSize)
+			}
+		})
+	}
+}
+
+func TestDownloadImageStatus(t *testing.T) {
+	w := fakeFileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+
+	if _, err := w.downloadImage(context.Background(), "id", 1024); err == nil {
+		t.Error("downloaded image despite error status")
+	}
+}
+
+func TestDownloadImageContext(t *testing.T) {
+	w := fakeFileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.downloadImage(ctx, "id", 1024)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("download did not fail after context was done")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("download did not stop after context was done")
+	}
+}
+
+func TestDownloadImageErrorHidesToken(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		timeout time.Duration
+	}{
+		{
+			name: "connection closed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				conn, _, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					t.Errorf("hijack: %s", err)
+					return
+				}
+				_ = conn.Close()
+			},
+		},
+		{
+			name:    "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) { <-r.Context().Done() },
+			timeout: 50 * time.Millisecond,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := fakeFileServer(t, tc.handler)
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			_, err := w.downloadImage(ctx, "id", 1024)
+			if err == nil {
+				t.Fatal("download did not fail")
+			}
+			if strings.Contains(err.Error(), testToken) {
+				t.Errorf("error contains the bot token: %s", err)
+			}
+		})
+	}
+}
+
+func TestAIImageFileIDChecksPersonaModel(t *testing.T) {
+	w := newTestWorker(t, nil)
+	config := w.config().AI
+	config.Model = "vision"
+	config.Personas = []ai.PersonaConfig{{Name: "blind", SystemPrompt: "Ты слепой.", Model: "text"}}
+	config.Images = ai.ImagesConfig{Enabled: true, Models: []string{"vision"}, MaxSize: 1024}
+
+	msg := testMessage()
+	msg.Photo = []telego.PhotoSize{{FileID: "small", FileSize: 100}, {FileID: "large", FileSize: 2048}}
+	for persona, want := range map[string]string{
+		ai.DefaultPersona: "small",
+		"blind":           "",
+	} {
+		if got := w.aiImageFileID(msg, persona); got != want {
+			t.Errorf("aiImageFileID() for persona %q = %q, want %q", persona, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
+)
+
+// settingLanguage is the chat setting that overrides the language of the chat.
+const settingLanguage = "language"
+
+func chatLanguageCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat_language:%d", chatID)
+}
+
+// language picks the language to answer msg in: the one set for the chat, user's language,
+// or the default one from config.
+func (w *worker) language(ctx context.Context, msg *telego.Message) texts.Language {
+	if lang, ok := texts.ParseLanguage(w.chatLanguage(ctx, msg.Chat.ID)); ok {
+		return lang
+	}
+	if msg.From != nil {
+		if lang, ok := texts.ParseLanguage(msg.From.LanguageCode); ok {
+			return lang
+		}
+	}
+	return w.texts().DefaultLanguage()
+}
+
+// chatLanguage returns the language set for the chat, or empty string if it is not set.
+func (w *worker) chatLanguage(ctx context.Context, chatID int64) string {
+	key := chatLanguageCacheKey(chatID)
+	if lang, ok := w.cache.Get(key); ok {
+		return lang.(string)
+	}
+
+	lang, _, err := w.db.GetChatSetting(ctx, int(chatID), settingLanguage)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to get chat language", "error", err)
+		return ""
+	}
+	w.cache.SetDefault(key, lang)
+	return lang
+}
+
+func (w *worker) handleLanguageRequest(ctx context.Context, msg *telego.Message) error {
+	args := commandArgs(msg)
+	if len(args) == 0 {
+		current := w.chatLanguage(ctx, msg.Chat.ID)
+		if current == "" {
+			current = "-"
+		}
+		return w.reply(ctx, msg, texts.LanguageCurrent, map[string]any{
+			"Language":  current,
+			"Languages": languageList(),
+		})
+	}
+
+	allowed, err := w.canManageChat(msg)
+	if err != nil {
+		return fmt.Errorf("check permissions: %w", err)
+	}
+	if !allowed {
+		return w.reply(ctx, msg, texts.NotChatAdmin, nil)
+	}
+
+	if args[0] == "reset" {
+		if err := w.db.DeleteChatSetting(ctx, int(msg.Chat.ID), settingLanguage); err != nil {
+			return fmt.Errorf("delete chat language: %w", err)
+		}
+		w.cache.Delete(chatLanguageCacheKey(msg.Chat.ID))
+		return w.reply(ctx, msg, texts.LanguageReset, nil)
+	}
+
+	lang, ok := texts.ParseLanguage(args[0])
+	if !ok {
+		return w.reply(ctx, msg, texts.LanguageUnknown, map[string]any{
+			"Language":  args[0],
+			"Languages": languageList(),
+		})
+	}
+
+	if err := w.db.SetChatSetting(ctx, int(msg.Chat.ID), settingLanguage, string(lang)); err != nil {
+		return fmt.Errorf("set chat language: %w", err)
+	}
+	w.cache.SetDefault(chatLanguageCacheKey(msg.Chat.ID), string(lang))
+	return w.reply(ctx, msg, texts.LanguageSet, nil)
+}
+
+func languageList() string {
+	langs := make([]string, 0, len(texts.Languages))
+	for _, lang := range texts.Languages {
+		langs = append(langs, string(lang))
+	}
+	return strings.Join(langs, ", ")
+}
@@ -0,0 +1,237 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/strategy"
+)
+
+type mediaItem struct {
+	kind strategy.Kind
+	// Either fileID or path is set.
+	fileID string
+	path   string
+	// hash of the local file contents identifies its uploaded file ID in the database.
+	hash string
+}
+
+type mediaCollection struct {
+	name   string
+	weight int
+	items  []mediaItem
+}
+
+// mediaLibrary holds media files from config grouped by kind.
+type mediaLibrary struct {
+	collections map[strategy.Kind][]mediaCollection
+}
+
+func newMediaLibrary(configs []MediaConfig) (*mediaLibrary, error) {
+	l := &mediaLibrary{
+		collections: make(map[strategy.Kind][]mediaCollection),
+	}
+
+	for _, config := range configs {
+		collection := mediaCollection{
+			name:   config.Name,
+			weight: weightOrDefault(config.Weight),
+		}
+		excluded := func(id string) bool {
+			return slices.Contains(config.Exclude, id)
+		}
+
+		for _, fileID := range config.FileIDs {
+			if !excluded(fileID) {
+				collection.items = append(collection.items, mediaItem{kind: config.Kind, fileID: fileID})
+			}
+		}
+
+		paths := slices.Clone(config.Files)
+		if config.Dir != "" {
+			entries, err := os.ReadDir(config.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("read media dir of %q: %w", config.Name, err)
+			}
+			for _, entry := range entries {
+				if entry.Type().IsRegular() && !excluded(entry.Name()) {
+					paths = append(paths, filepath.Join(config.Dir, entry.Name()))
+				}
+			}
+		}
+		for _, path := range paths {
+			hash, err := hashFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("hash media file of %q: %w", config.Name, err)
+			}
+			collection.items = append(collection.items, mediaItem{kind: config.Kind, path: path, hash: hash})
+		}
+
+		if len(collection.items) > 0 && collection.weight > 0 {
+			l.collections[config.Kind] = append(l.collections[config.Kind], collection)
+		}
+	}
+
+	return l, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pick returns a random item of the kind, choosing the collection by weight first.
+func (l *mediaLibrary) pick(rng *rand.Rand, kind strategy.Kind) (mediaItem, bool) {
+	collection, ok := pickWeighted(rng, l.collections[kind], func(collection mediaCollection) int {
+		return collection.weight
+	})
+	if !ok {
+		return mediaItem{}, false
+	}
+	return collection.items[rng.IntN(len(collection.items))], true
+}
+
+type mediaResponse struct {
+	triggerResponseBase
+	item mediaItem
+	// fileID is empty if the local file has not been uploaded yet.
+	fileID string
+	// uploaded is called with file ID assigned by Telegram after the local file is uploaded.
+	uploaded func(fileID string)
+}
+
+func (m *mediaResponse) sendReply(api *telego.Bot, chatID telego.ChatID, replyParams *telego.ReplyParameters) error {
+	file := telego.InputFile{FileID: m.fileID}
+	if m.fileID == "" {
+		f, err := os.Open(m.item.path)
+		if err != nil {
+			return fmt.Errorf("open media file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		file = telego.InputFile{File: f}
+	}
+
+	var sent *telego.Message
+	var err error
+	switch m.item.kind {
+	case strategy.KindAnimation:
+		sent, err = api.SendAnimation(&telego.SendAnimationParams{
+			ChatID:          chatID,
+			Animation:       file,
+			ReplyParameters: replyParams,
+		})
+	case strategy.KindVoice:
+		sent, err = api.SendVoice(&telego.SendVoiceParams{
+			ChatID:          chatID,
+			Voice:           file,
+			ReplyParameters: replyParams,
+		})
+	case strategy.KindAudio:
+		sent, err = api.SendAudio(&telego.SendAudioParams{
+			ChatID:          chatID,
+			Audio:           file,
+			ReplyParameters: replyParams,
+		})
+	case strategy.KindPhoto:
+		sent, err = api.SendPhoto(&telego.SendPhotoParams{
+			ChatID:          chatID,
+			Photo:           file,
+			ReplyParameters: replyParams,
+		})
+	default:
+		return fmt.Errorf("unknown media kind %q", m.item.kind)
+	}
+	if err != nil {
+		return fmt.Errorf("send %s: %w", m.item.kind, err)
+	}
+
+	if m.fileID == "" && m.uploaded != nil {
+		if fileID := sentFileID(sent); fileID != "" {
+			m.uploaded(fileID)
+		}
+	}
+	return nil
+}
+
+func sentFileID(msg *telego.Message) string {
+	switch {
+	case msg == nil:
+		return ""
+	case msg.Animation != nil:
+		return msg.Animation.FileID
+	case msg.Voice != nil:
+		return msg.Voice.FileID
+	case msg.Audio != nil:
+		return msg.Audio.FileID
+	case len(msg.Photo) > 0:
+		// Sizes are sorted in ascending order, the last one is the original.
+		return msg.Photo[len(msg.Photo)-1].FileID
+	default:
+		return ""
+	}
+}
+
+func mediaFileCacheKey(hash string) string {
+	return "media_file:" + hash
+}
+
+func (w *worker) makeMediaResponse(ctx context.Context, trigger trigger, msg *telego.Message, kind strategy.Kind) triggerResponse {
+	item, ok := w.media().pick(w.rng, kind)
+	if !ok {
+		return w.makeDefaultResponse(ctx, trigger, msg)
+	}
+
+	response := &mediaResponse{
+		triggerResponseBase: triggerResponseBase{
+			t: trigger, typ: responseType(kind),
+		},
+		item:   item,
+		fileID: item.fileID,
+	}
+	if item.path == "" {
+		return response
+	}
+
+	key := mediaFileCacheKey(item.hash)
+	if fileID, ok := w.cache.Get(key); ok {
+		response.fileID = fileID.(string)
+		return response
+	}
+
+	fileID, ok, err := w.db.GetMediaFileID(ctx, item.hash)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to get media file id, uploading file again", "path", item.path, "error", err)
+	}
+	if ok {
+		w.cache.Set(key, fileID, 0)
+		response.fileID = fileID
+		return response
+	}
+
+	response.uploaded = func(fileID string) {
+		w.log.InfoContext(ctx, "uploaded media file", "path", item.path, "fileId", fileID)
+		w.cache.Set(key, fileID, 0)
+		if err := w.db.SetMediaFileID(ctx, item.hash, fileID); err != nil {
+			w.log.ErrorContext(ctx, "failed to save media file id", "path", item.path, "error", err)
+		}
+	}
+	return response
+}
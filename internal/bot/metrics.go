@@ -19,6 +19,9 @@ const (
 	labelUpdateType   = "update_type"
 	labelTriggerType  = "trigger_type"
 	labelResponseType = "response_type"
+	labelStatus       = "status"
+	labelReason       = "reason"
+	labelAction       = "action"
 )
 
 var (
@@ -71,6 +74,29 @@ var (
 		[]string{labelChatID, labelResponseType},
 	)
 
+	spamDetected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "spam_detected_count",
+			Help: "Number of messages detected as spam by reason and action taken",
+		},
+		[]string{labelChatID, labelReason, labelAction},
+	)
+
+	aiJobResults = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_jobs_count",
+			Help: "Number of AI generation jobs by how they finished",
+		},
+		[]string{labelStatus},
+	)
+
+	runningAIJobs = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "running_ai_jobs",
+			Help: "Number of AI generation jobs that are queued or running",
+		},
+	)
+
 	totalUsers = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "total_users_count",
@@ -84,6 +110,36 @@ var (
 			Help: "Total number of chats",
 		},
 	)
+
+	configReloads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_count",
+			Help: "Number of config reload attempts",
+		},
+		[]string{labelStatus},
+	)
+
+	lastConfigReloadTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "last_config_reload_timestamp_seconds",
+			Help: "Unix time of the last successful config reload",
+		},
+	)
+
+	backups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backups_count",
+			Help: "Number of database backup attempts",
+		},
+		[]string{labelStatus},
+	)
+
+	lastBackupTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "last_backup_timestamp_seconds",
+			Help: "Unix time of the last successful database backup",
+		},
+	)
 )
 
 func (b *Bot) runMetricsServer(ctx context.Context) {
@@ -97,7 +153,7 @@ func (b *Bot) runMetricsServer(ctx context.Context) {
 
 			default:
 				logger.Debug("Starting metrics server")
-				if err := http.ListenAndServe(b.config.Metrics.Addr, nil); err != nil {
+				if err := http.ListenAndServe(b.config().Metrics.Addr, nil); err != nil {
 					logger.ErrorContext(ctx, "failed to start metrics server", "error", err)
 				}
 				time.Sleep(1 * time.Second)
@@ -106,10 +162,10 @@ func (b *Bot) runMetricsServer(ctx context.Context) {
 	}()
 
 	go func() {
-		var dbconn *db.DB
+		var storage db.Storage
 		var err error
 
-		ticker := time.NewTicker(b.config.Metrics.UpdatePeriod)
+		ticker := time.NewTicker(b.config().Metrics.UpdatePeriod)
 		defer ticker.Stop()
 
 		for {
@@ -120,18 +176,19 @@ func (b *Bot) runMetricsServer(ctx context.Context) {
 			case <-ticker.C:
 			}
 
-			if dbconn == nil {
-				dbconn, err = db.NewDB(b.dbPath)
+			if storage == nil {
+				storage, err = b.openStorage(ctx)
 				if err != nil {
-					dbconn = nil
+					storage = nil
 					logger.ErrorContext(ctx, "failed to connect to database", "error", err)
 					continue
 				}
 			}
 
-			stats, err := dbconn.GetStats(ctx)
+			stats, err := storage.GetStats(ctx)
 			if err != nil {
-				dbconn = nil
+				_ = storage.Close()
+				storage = nil
 				logger.ErrorContext(ctx, "failed to get stats", "error", err)
 				continue
 			}
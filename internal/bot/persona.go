@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
+)
+
+// settingPersona is the chat setting that overrides the AI persona of the chat.
+const settingPersona = "ai_persona"
+
+func chatPersonaCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat_persona:%d", chatID)
+}
+
+// persona returns the AI persona set for the chat, or the default one from config.
+func (w *worker) persona(ctx context.Context, chatID int64) string {
+	if persona := w.chatPersona(ctx, chatID); w.config().AI.HasPersona(persona) {
+		return persona
+	}
+	return w.config().AI.DefaultPersona
+}
+
+// chatPersona returns the persona set for the chat, or empty string if it is not set.
+func (w *worker) chatPersona(ctx context.Context, chatID int64) string {
+	key := chatPersonaCacheKey(chatID)
+	if persona, ok := w.cache.Get(key); ok {
+		return persona.(string)
+	}
+
+	persona, _, err := w.db.GetChatSetting(ctx, int(chatID), settingPersona)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to get chat persona", "error", err)
+		return ""
+	}
+	w.cache.SetDefault(key, persona)
+	return persona
+}
+
+func (w *worker) handlePersonaRequest(ctx context.Context, msg *telego.Message) error {
+	args := commandArgs(msg)
+	if len(args) == 0 {
+		return w.reply(ctx, msg, texts.PersonaCurrent, map[string]any{
+			"Persona":  w.persona(ctx, msg.Chat.ID),
+			"Personas": w.personaList(),
+		})
+	}
+
+	allowed, err := w.canManageChat(msg)
+	if err != nil {
+		return fmt.Errorf("check permissions: %w", err)
+	}
+	if !allowed {
+		return w.reply(ctx, msg, texts.NotChatAdmin, nil)
+	}
+
+	if args[0] == "reset" {
+		if err := w.db.DeleteChatSetting(ctx, int(msg.Chat.ID), settingPersona); err != nil {
+			return fmt.Errorf("delete chat persona: %w", err)
+		}
+		w.cache.Delete(chatPersonaCacheKey(msg.Chat.ID))
+		return w.reply(ctx, msg, texts.PersonaReset, map[string]any{
+			"Persona": w.persona(ctx, msg.Chat.ID),
+		})
+	}
+
+	persona := args[0]
+	if !w.config().AI.HasPersona(persona) {
+		return w.reply(ctx, msg, texts.PersonaUnknown, map[string]any{
+			"Persona":  persona,
+			"Personas": w.personaList(),
+		})
+	}
+
+	if err := w.db.SetChatSetting(ctx, int(msg.Chat.ID), settingPersona, persona); err != nil {
+		return fmt.Errorf("set chat persona: %w", err)
+	}
+	w.cache.SetDefault(chatPersonaCacheKey(msg.Chat.ID), persona)
+	return w.reply(ctx, msg, texts.PersonaSet, map[string]any{"Persona": persona})
+}
+
+func (w *worker) personaList() string {
+	return strings.Join(append(w.config().AI.PersonaNames(), ai.RandomPersona), ", ")
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/logging"
+)
+
+// Editors and config management tools usually write files in several steps,
+// so changes are debounced before reloading.
+const configReloadDebounce = 500 * time.Millisecond
+
+func (b *Bot) watchConfig(ctx context.Context, aiHandler *ai.AI, cache *cache.Cache) {
+	log := logging.New("config")
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	// The directory is watched instead of the file itself, because editors and
+	// kubernetes/docker config mounts replace the file rather than write to it.
+	var fileEvents <-chan fsnotify.Event
+	var fileErrors <-chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.ErrorContext(ctx, "failed to create config file watcher, only SIGHUP reloads are available", "error", err)
+	} else if err := watcher.Add(filepath.Dir(b.configPath)); err != nil {
+		log.ErrorContext(ctx, "failed to watch config file, only SIGHUP reloads are available", "error", err)
+		_ = watcher.Close()
+		watcher = nil
+	} else {
+		fileEvents = watcher.Events
+		fileErrors = watcher.Errors
+	}
+
+	go func() {
+		defer signal.Stop(sighup)
+		if watcher != nil {
+			defer func() { _ = watcher.Close() }()
+		}
+
+		debounce := time.NewTimer(configReloadDebounce)
+		debounce.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-sighup:
+				log.InfoContext(ctx, "received SIGHUP, reloading config")
+				b.reloadConfig(ctx, log, aiHandler, cache)
+
+			case event := <-fileEvents:
+				if filepath.Clean(event.Name) != filepath.Clean(b.configPath) {
+					continue
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				log.DebugContext(ctx, "config file changed", "event", event.Op.String())
+				debounce.Reset(configReloadDebounce)
+
+			case err := <-fileErrors:
+				log.WarnContext(ctx, "config file watcher error", "error", err)
+
+			case <-debounce.C:
+				log.InfoContext(ctx, "config file changed, reloading config")
+				b.reloadConfig(ctx, log, aiHandler, cache)
+			}
+		}
+	}()
+}
+
+func (b *Bot) reloadConfig(ctx context.Context, log *slog.Logger, aiHandler *ai.AI, cache *cache.Cache) {
+	if err := b.applyConfig(ctx, log, aiHandler, cache); err != nil {
+		configReloads.WithLabelValues("failure").Inc()
+		log.ErrorContext(ctx, "failed to reload config, keeping the previous one", "error", err)
+		return
+	}
+
+	configReloads.WithLabelValues("success").Inc()
+	lastConfigReloadTimestamp.SetToCurrentTime()
+	log.InfoContext(ctx, "config reloaded")
+}
+
+func (b *Bot) applyConfig(ctx context.Context, log *slog.Logger, aiHandler *ai.AI, cache *cache.Cache) error {
+	newConfig, err := LoadConfig(b.configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if err := newConfig.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	st, err := newState(newConfig)
+	if err != nil {
+		return err
+	}
+
+	oldConfig := b.config()
+	if newConfig.BotToken != oldConfig.BotToken {
+		log.WarnContext(ctx, "bot token changed, restart is required to apply it")
+	}
+	if newConfig.AIJobs.Concurrency != oldConfig.AIJobs.Concurrency || newConfig.AIJobs.QueueSize != oldConfig.AIJobs.QueueSize {
+		log.WarnContext(ctx, "ai job pool size changed, restart is required to apply it")
+	}
+	if aiHandler == nil && newConfig.AI.Enabled() {
+		log.WarnContext(ctx, "AI API key was set, restart is required to enable AI responses")
+	}
+
+	if aiHandler != nil {
+		if err := aiHandler.UpdateConfig(newConfig.AI); err != nil {
+			return fmt.Errorf("update ai config: %w", err)
+		}
+	}
+
+	b.state.Store(st)
+
+	// Drop cached sticker sets so that changed exclusions are applied
+	// and removed sets stop being used.
+	for _, stickerSet := range oldConfig.StickerSets {
+		cache.Delete(stickerSetCacheKey(stickerSet.Name))
+	}
+	for _, stickerSet := range newConfig.StickerSets {
+		cache.Delete(stickerSetCacheKey(stickerSet.Name))
+	}
+
+	return nil
+}
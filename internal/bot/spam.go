@@ -0,0 +1,277 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
+)
+
+// settingSpamSensitivity is the chat setting that overrides spam sensitivity of the chat.
+const settingSpamSensitivity = "spam_sensitivity"
+
+type spamReason string
+
+const (
+	spamTooManyTriggers spamReason = "too_many_triggers"
+	spamUserFlood       spamReason = "user_flood"
+	spamChatFlood       spamReason = "chat_flood"
+	spamMuted           spamReason = "muted"
+)
+
+type spamAction string
+
+const (
+	spamIgnore spamAction = "ignore"
+	spamWarn   spamAction = "warn"
+	spamMute   spamAction = "mute"
+)
+
+func chatSpamSensitivityCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat_spam_sensitivity:%d", chatID)
+}
+
+func userTriggersCacheKey(chatID, userID int64) string {
+	return fmt.Sprintf("user_triggers:%d:%d", chatID, userID)
+}
+
+func chatTriggersCacheKey(chatID int64) string {
+	return fmt.Sprintf("chat_triggers:%d", chatID)
+}
+
+func spamStrikesCacheKey(chatID, userID int64) string {
+	return fmt.Sprintf("spam_strikes:%d:%d", chatID, userID)
+}
+
+func spamWarnedCacheKey(chatID, userID int64) string {
+	return fmt.Sprintf("spam_warned:%d:%d", chatID, userID)
+}
+
+func mutedUserCacheKey(chatID, userID int64) string {
+	return fmt.Sprintf("muted_user:%d:%d", chatID, userID)
+}
+
+// slidingWindow counts events that happened within the last window. Windows are shared
+// between workers through the cache, and are guarded by worker.windowsMu.
+type slidingWindow struct {
+	events []time.Time
+}
+
+func (s *slidingWindow) add(now time.Time, window time.Duration) int {
+	cutoff := now.Add(-window)
+	expired := 0
+	for expired < len(s.events) && !s.events[expired].After(cutoff) {
+		expired++
+	}
+	s.events = append(s.events[expired:], now)
+	return len(s.events)
+}
+
+// countInWindow records an event under the key and returns the number of events within the window.
+// Windows are kept in cache until there are no events for the whole window.
+func (w *worker) countInWindow(key string, now time.Time, window time.Duration) int {
+	// The window is read and stored under the lock, so that concurrent events are not lost
+	// to a window that is replaced, or that expires in between.
+	w.windowsMu.Lock()
+	defer w.windowsMu.Unlock()
+
+	counter := &slidingWindow{}
+	if v, ok := w.cache.Get(key); ok {
+		counter = v.(*slidingWindow)
+	}
+	count := counter.add(now, window)
+	w.cache.Set(key, counter, window)
+	return count
+}
+
+// preventSpam reports whether msg is spam, in which case it must be neither answered
+// nor counted in stats. Senders of spam are warned and muted as described in SpamConfig.
+func (w *worker) preventSpam(ctx context.Context, msg *telego.Message, triggers []trigger) (bool, error) {
+	if _, muted := w.cache.Get(mutedUserCacheKey(msg.Chat.ID, msg.From.ID)); muted {
+		w.log.DebugContext(ctx, "ignoring message of muted user")
+		spamDetected.WithLabelValues(chatIdLabel(msg), string(spamMuted), string(spamIgnore)).Inc()
+		return true, nil
+	}
+
+	sensitivity := w.spamSensitivity(ctx, msg.Chat.ID)
+	if sensitivity == SpamSensitivityOff {
+		return false, nil
+	}
+
+	now := time.Now()
+	reason := w.detectSpam(ctx, msg, triggers, sensitivity, now)
+	if reason == "" {
+		return false, nil
+	}
+
+	action, err := w.punishSpam(ctx, msg, triggers, reason, now)
+	w.log.InfoContext(ctx, "detected spam", "reason", reason, "action", action)
+	spamDetected.WithLabelValues(chatIdLabel(msg), string(reason), string(action)).Inc()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (w *worker) detectSpam(ctx context.Context, msg *telego.Message, triggers []trigger, sensitivity SpamSensitivity, now time.Time) spamReason {
+	config := w.config().Spam
+
+	userCount := w.countInWindow(userTriggersCacheKey(msg.Chat.ID, msg.From.ID), now, config.Window)
+	chatCount := w.countInWindow(chatTriggersCacheKey(msg.Chat.ID), now, config.Window)
+
+	triggerCount := len(triggers)
+	triggersLength := 0
+	for _, trigger := range triggers {
+		triggersLength += trigger.runeLength
+	}
+	text, _ := messageText(msg)
+	textLength := utf8.RuneCountInString(text)
+
+	w.log.DebugContext(ctx, "checking for spam",
+		slog.Int("triggerCount", triggerCount),
+		slog.Int("triggersLength", triggersLength),
+		slog.Int("textLength", textLength),
+		slog.Int("userCount", userCount),
+		slog.Int("chatCount", chatCount),
+		slog.String("sensitivity", string(sensitivity)),
+	)
+
+	switch {
+	case tooManyTriggers(triggerCount, triggersLength, textLength):
+		return spamTooManyTriggers
+	case userCount > scaleSpamLimit(config.UserLimit, sensitivity):
+		return spamUserFlood
+	case chatCount > scaleSpamLimit(config.ChatLimit, sensitivity):
+		return spamChatFlood
+	default:
+		return ""
+	}
+}
+
+func scaleSpamLimit(limit int, sensitivity SpamSensitivity) int {
+	switch sensitivity {
+	case SpamSensitivityLow:
+		return limit * 2
+	case SpamSensitivityHigh:
+		return max(limit/2, 1)
+	default:
+		return limit
+	}
+}
+
+// punishSpam escalates from ignoring the sender silently to a warning and then to a mute.
+func (w *worker) punishSpam(ctx context.Context, msg *telego.Message, triggers []trigger, reason spamReason, now time.Time) (spamAction, error) {
+	// The whole chat is flooding, which is not the fault of this particular sender.
+	if reason == spamChatFlood {
+		return spamIgnore, nil
+	}
+
+	config := w.config().Spam
+	strikes := w.countInWindow(spamStrikesCacheKey(msg.Chat.ID, msg.From.ID), now, config.Window)
+
+	switch {
+	case strikes >= config.MuteAfter:
+		w.cache.Set(mutedUserCacheKey(msg.Chat.ID, msg.From.ID), struct{}{}, config.MuteDuration)
+		return spamMute, w.reply(ctx, msg, texts.SpamMuted, map[string]any{
+			"User":    messageUser(msg),
+			"Minutes": int(math.Ceil(config.MuteDuration.Minutes())),
+		})
+
+	case strikes >= config.WarnAfter:
+		// Warnings are sent once per window, otherwise they would be spammable too.
+		if err := w.cache.Add(spamWarnedCacheKey(msg.Chat.ID, msg.From.ID), struct{}{}, config.Window); err != nil {
+			return spamIgnore, nil
+		}
+		return spamWarn, w.reply(ctx, msg, texts.Spam, triggerTextData(msg, triggers[0]))
+
+	default:
+		return spamIgnore, nil
+	}
+}
+
+// spamSensitivity returns the sensitivity set for the chat with /spam, falling back to config.
+func (w *worker) spamSensitivity(ctx context.Context, chatID int64) SpamSensitivity {
+	if sensitivity := SpamSensitivity(w.chatSpamSensitivity(ctx, chatID)); slices.Contains(SpamSensitivities, sensitivity) {
+		return sensitivity
+	}
+
+	config := w.config().Spam
+	if sensitivity, ok := config.Chats[chatID]; ok {
+		return sensitivity
+	}
+	return config.Sensitivity
+}
+
+// chatSpamSensitivity returns the sensitivity set for the chat, or empty string if it is not set.
+func (w *worker) chatSpamSensitivity(ctx context.Context, chatID int64) string {
+	key := chatSpamSensitivityCacheKey(chatID)
+	if sensitivity, ok := w.cache.Get(key); ok {
+		return sensitivity.(string)
+	}
+
+	sensitivity, _, err := w.db.GetChatSetting(ctx, int(chatID), settingSpamSensitivity)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to get chat spam sensitivity", "error", err)
+		return ""
+	}
+	w.cache.SetDefault(key, sensitivity)
+	return sensitivity
+}
+
+func (w *worker) handleSpamRequest(ctx context.Context, msg *telego.Message) error {
+	args := commandArgs(msg)
+	if len(args) == 0 {
+		return w.reply(ctx, msg, texts.SpamSensitivityCurrent, map[string]any{
+			"Sensitivity":   w.spamSensitivity(ctx, msg.Chat.ID),
+			"Sensitivities": spamSensitivityList(),
+		})
+	}
+
+	allowed, err := w.canManageChat(msg)
+	if err != nil {
+		return fmt.Errorf("check permissions: %w", err)
+	}
+	if !allowed {
+		return w.reply(ctx, msg, texts.NotChatAdmin, nil)
+	}
+
+	if args[0] == "reset" {
+		if err := w.db.DeleteChatSetting(ctx, int(msg.Chat.ID), settingSpamSensitivity); err != nil {
+			return fmt.Errorf("delete chat spam sensitivity: %w", err)
+		}
+		w.cache.Delete(chatSpamSensitivityCacheKey(msg.Chat.ID))
+		return w.reply(ctx, msg, texts.SpamSensitivityReset, map[string]any{
+			"Sensitivity": w.spamSensitivity(ctx, msg.Chat.ID),
+		})
+	}
+
+	sensitivity := SpamSensitivity(strings.ToLower(args[0]))
+	if !slices.Contains(SpamSensitivities, sensitivity) {
+		return w.reply(ctx, msg, texts.SpamSensitivityUnknown, map[string]any{
+			"Sensitivity":   args[0],
+			"Sensitivities": spamSensitivityList(),
+		})
+	}
+
+	if err := w.db.SetChatSetting(ctx, int(msg.Chat.ID), settingSpamSensitivity, string(sensitivity)); err != nil {
+		return fmt.Errorf("set chat spam sensitivity: %w", err)
+	}
+	w.cache.SetDefault(chatSpamSensitivityCacheKey(msg.Chat.ID), string(sensitivity))
+	return w.reply(ctx, msg, texts.SpamSensitivitySet, map[string]any{"Sensitivity": sensitivity})
+}
+
+func spamSensitivityList() string {
+	sensitivities := make([]string, 0, len(SpamSensitivities))
+	for _, sensitivity := range SpamSensitivities {
+		sensitivities = append(sensitivities, string(sensitivity))
+	}
+	return strings.Join(sensitivities, ", ")
+}
@@ -0,0 +1,189 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowAdd(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := time.Minute
+
+	s := &slidingWindow{}
+	steps := []struct {
+		at   time.Duration
+		want int
+	}{
+		{at: 0, want: 1},
+		{at: 10 * time.Second, want: 2},
+		{at: 30 * time.Second, want: 3},
+		// The first event is exactly a window old, so it is no longer counted.
+		{at: time.Minute, want: 3},
+		{at: 75 * time.Second, want: 3},
+		{at: 5 * time.Minute, want: 1},
+	}
+	for _, step := range steps {
+		if got := s.add(start.Add(step.at), window); got != step.want {
+			t.Errorf("add() at %s = %d, want %d", step.at, got, step.want)
+		}
+	}
+}
+
+func TestCountInWindowExpires(t *testing.T) {
+	w := newTestWorker(t, nil)
+	now := time.Now()
+
+	for i := range 3 {
+		if got := w.countInWindow("key", now.Add(time.Duration(i)*time.Second), time.Minute); got != i+1 {
+			t.Errorf("count %d = %d, want %d", i, got, i+1)
+		}
+	}
+	if got := w.countInWindow("key", now.Add(2*time.Minute), time.Minute); got != 1 {
+		t.Errorf("count after window = %d, want 1", got)
+	}
+}
+
+func TestPunishSpamEscalates(t *testing.T) {
+	api, fake := newFakeTelegram(t, nil)
+	w := newTestWorker(t, api)
+	msg := testMessage()
+	triggers := []trigger{{quote: "гойда", runeLength: 5, typ: zov}}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// With the defaults, the second strike is warned, the third is ignored as the sender
+	// is already warned, and the fourth is muted.
+	want := []spamAction{spamIgnore, spamWarn, spamIgnore, spamMute}
+	for i, wantAction := range want {
+		action, err := w.punishSpam(context.Background(), msg, triggers, spamUserFlood, now.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("punish spam: %s", err)
+		}
+		if action != wantAction {
+			t.Errorf("strike %d: action %q, want %q", i+1, action, wantAction)
+		}
+	}
+
+	if got, want := fake.called(), []string{"sendMessage", "sendMessage"}; !slices.Equal(got, want) {
+		t.Errorf("called %v, want %v", got, want)
+	}
+	if _, muted := w.cache.Get(mutedUserCacheKey(msg.Chat.ID, msg.From.ID)); !muted {
+		t.Error("user is not muted")
+	}
+}
+
+func TestPunishSpamStrikesExpire(t *testing.T) {
+	api, fake := newFakeTelegram(t, nil)
+	w := newTestWorker(t, api)
+	msg := testMessage()
+	triggers := []trigger{{quote: "гойда", runeLength: 5, typ: zov}}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Duration{0, 2 * time.Minute, 4 * time.Minute} {
+		action, err := w.punishSpam(context.Background(), msg, triggers, spamUserFlood, now.Add(at))
+		if err != nil {
+			t.Fatalf("punish spam: %s", err)
+		}
+		if action != spamIgnore {
+			t.Errorf("strike at %s: action %q, want %q", at, action, spamIgnore)
+		}
+	}
+	if got := fake.called(); len(got) != 0 {
+		t.Errorf("called %v, want nothing", got)
+	}
+}
+
+func TestPunishSpamChatFlood(t *testing.T) {
+	api, fake := newFakeTelegram(t, nil)
+	w := newTestWorker(t, api)
+	msg := testMessage()
+	triggers := []trigger{{quote: "гойда", runeLength: 5, typ: zov}}
+	now := time.Now()
+
+	for i := range 10 {
+		action, err := w.punishSpam(context.Background(), msg, triggers, spamChatFlood, now.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("punish spam: %s", err)
+		}
+		if action != spamIgnore {
+			t.Errorf("flood %d: action %q, want %q", i+1, action, spamIgnore)
+		}
+	}
+	if got := fake.called(); len(got) != 0 {
+		t.Errorf("called %v, want nothing", got)
+	}
+}
+
+func TestScaleSpamLimit(t *testing.T) {
+	tests := []struct {
+		limit       int
+		sensitivity SpamSensitivity
+		want        int
+	}{
+		{limit: 6, sensitivity: SpamSensitivityLow, want: 12},
+		{limit: 6, sensitivity: SpamSensitivityNormal, want: 6},
+		{limit: 6, sensitivity: SpamSensitivityHigh, want: 3},
+		{limit: 1, sensitivity: SpamSensitivityHigh, want: 1},
+	}
+	for _, tt := range tests {
+		if got := scaleSpamLimit(tt.limit, tt.sensitivity); got != tt.want {
+			t.Errorf("scaleSpamLimit(%d, %q) = %d, want %d", tt.limit, tt.sensitivity, got, tt.want)
+		}
+	}
+}
+
+func TestSpamSensitivity(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWorker(t, nil)
+	w.config().Spam.Chats = map[int64]SpamSensitivity{2: SpamSensitivityLow, 3: SpamSensitivityLow}
+	if err := w.db.SetChatSetting(ctx, 3, settingSpamSensitivity, string(SpamSensitivityHigh)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		chatID int64
+		want   SpamSensitivity
+	}{
+		{chatID: 1, want: SpamSensitivityNormal},
+		{chatID: 2, want: SpamSensitivityLow},
+		{chatID: 3, want: SpamSensitivityHigh},
+	}
+	for _, tt := range tests {
+		if got := w.spamSensitivity(ctx, tt.chatID); got != tt.want {
+			t.Errorf("spamSensitivity(%d) = %q, want %q", tt.chatID, got, tt.want)
+		}
+	}
+}
+
+func TestDetectSpamSensitivity(t *testing.T) {
+	tests := []struct {
+		sensitivity SpamSensitivity
+		// floodAt is the number of the first message that is a flood.
+		floodAt int
+	}{
+		{sensitivity: SpamSensitivityLow, floodAt: 13},
+		{sensitivity: SpamSensitivityNormal, floodAt: 7},
+		{sensitivity: SpamSensitivityHigh, floodAt: 4},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.sensitivity), func(t *testing.T) {
+			w := newTestWorker(t, nil)
+			msg := testMessage()
+			msg.Text = strings.Repeat("слово ", 20) + "гойда"
+			triggers := []trigger{{quote: "гойда", runeLength: 5, typ: zov}}
+			now := time.Now()
+
+			for i := 1; i <= tt.floodAt; i++ {
+				reason := w.detectSpam(context.Background(), msg, triggers, tt.sensitivity, now.Add(time.Duration(i)*time.Second))
+				switch {
+				case i < tt.floodAt && reason != "":
+					t.Fatalf("message %d: detected %q, want no spam", i, reason)
+				case i == tt.floodAt && reason != spamUserFlood:
+					t.Fatalf("message %d: detected %q, want %q", i, reason, spamUserFlood)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/detector"
+	"github.com/LeKSuS-04/svoi-bot/internal/strategy"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
+)
+
+// state holds config together with everything derived from it,
+// so that all of it is replaced at once on config reload.
+type state struct {
+	config   *Config
+	detector *detector.Detector
+	strategy *strategy.Engine
+	texts    *texts.Library
+	media    *mediaLibrary
+}
+
+func newState(config *Config) (*state, error) {
+	strategyEngine, err := strategy.New(config.Responses)
+	if err != nil {
+		return nil, fmt.Errorf("create response strategy: %w", err)
+	}
+
+	textLibrary, err := texts.New(config.Texts)
+	if err != nil {
+		return nil, fmt.Errorf("create text library: %w", err)
+	}
+
+	media, err := newMediaLibrary(config.Media)
+	if err != nil {
+		return nil, fmt.Errorf("create media library: %w", err)
+	}
+
+	return &state{
+		config:   config,
+		detector: detector.New(config.Triggers),
+		strategy: strategyEngine,
+		texts:    textLibrary,
+		media:    media,
+	}, nil
+}
@@ -1,43 +1,124 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand/v2"
 	"slices"
+	"strings"
 
 	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/detector"
 )
 
+type sticker struct {
+	fileID string
+	// uniqueID stays the same over time, unlike fileID.
+	uniqueID string
+	emoji    string
+	weight   int
+}
+
+type pickedSticker struct {
+	sticker
+	setName string
+}
+
 func stickerSetCacheKey(stickerSetName string) string {
 	return "sticker_set:" + stickerSetName
 }
 
-func (w *worker) getSticker() (stickerFileID string, err error) {
-	stickerSetConfig := w.config.StickerSets[rand.IntN(len(w.config.StickerSets))]
-	key := stickerSetCacheKey(stickerSetConfig.Name)
+func recentStickersCacheKey(chatID int64) string {
+	return fmt.Sprintf("recent_stickers:%d", chatID)
+}
+
+// getSticker picks a sticker for a trigger in the chat: a set is picked by weight among
+// the sets configured for the chat, and then a sticker is picked by weight, preferring
+// the ones with emojis matching the trigger and the ones not sent to the chat recently.
+func (w *worker) getSticker(ctx context.Context, chatID int64, triggerType detector.Type) (pickedSticker, error) {
+	config := w.config()
+
+	stickerSets, err := w.stickerSets(ctx)
+	if err != nil {
+		return pickedSticker{}, fmt.Errorf("get sticker sets: %w", err)
+	}
+	if names, ok := config.Stickers.Chats[chatID]; ok {
+		stickerSets = slices.DeleteFunc(slices.Clone(stickerSets), func(stickerSet StickerSetConfig) bool {
+			return !slices.Contains(names, stickerSet.Name)
+		})
+	}
+	stickerSetConfig, ok := pickWeighted(w.rng, stickerSets, func(stickerSet StickerSetConfig) int {
+		return weightOrDefault(stickerSet.Weight)
+	})
+	if !ok {
+		return pickedSticker{}, errors.New("no sticker sets to choose from")
+	}
+
+	stickers, err := w.getStickerSet(ctx, stickerSetConfig)
+	if err != nil {
+		return pickedSticker{}, fmt.Errorf("get sticker set: %w", err)
+	}
+
+	candidates := stickers
+	if emojis := config.Stickers.TriggerEmojis[triggerType]; len(emojis) > 0 {
+		matching := slices.DeleteFunc(slices.Clone(candidates), func(s sticker) bool {
+			return !slices.ContainsFunc(emojis, func(emoji string) bool {
+				return sameEmoji(emoji, s.emoji)
+			})
+		})
+		if len(matching) > 0 {
+			candidates = matching
+		}
+	}
+
+	if recent := w.recentStickers(chatID); len(recent) > 0 {
+		fresh := slices.DeleteFunc(slices.Clone(candidates), func(s sticker) bool {
+			return slices.Contains(recent, s.uniqueID)
+		})
+		if len(fresh) > 0 {
+			candidates = fresh
+		}
+	}
+
+	picked, ok := pickWeighted(w.rng, candidates, func(s sticker) int {
+		return s.weight
+	})
+	if !ok {
+		return pickedSticker{}, fmt.Errorf("no stickers to choose from in set %q", stickerSetConfig.Name)
+	}
+	return pickedSticker{sticker: picked, setName: stickerSetConfig.Name}, nil
+}
+
+// sameEmoji compares emojis ignoring variation selectors, which are not always present.
+func sameEmoji(a, b string) bool {
+	return strings.ReplaceAll(a, "\ufe0f", "") == strings.ReplaceAll(b, "\ufe0f", "")
+}
 
+func (w *worker) getStickerSet(ctx context.Context, stickerSetConfig StickerSetConfig) ([]sticker, error) {
+	key := stickerSetCacheKey(stickerSetConfig.Name)
 	v, err, _ := w.getStickerSetG.Do(key, func() (any, error) {
 		set, ok := w.cache.Get(key)
 		if ok {
 			return set, nil
 		}
-		stickerFileIDs, err := w.loadStickerSet(stickerSetConfig)
+		stickers, err := w.loadStickerSet(ctx, stickerSetConfig)
 		if err != nil {
 			return nil, fmt.Errorf("load sticker set: %w", err)
 		}
-		w.cache.Set(key, stickerFileIDs, 0)
-		return stickerFileIDs, nil
+		w.cache.Set(key, stickers, w.config().Stickers.RefreshInterval)
+		return stickers, nil
 	})
 	if err != nil {
-		return "", fmt.Errorf("get sticker set: %w", err)
+		return nil, err
 	}
-
-	setStr := v.([]string)
-	stickerFileID = setStr[rand.IntN(len(setStr))]
-	return stickerFileID, nil
+	return v.([]sticker), nil
 }
 
-func (w *worker) loadStickerSet(stickerSetConfig StickerSetConfig) (stickerFileIDs []string, _ error) {
+// loadStickerSet loads stickers of the set, skipping the ones excluded in config
+// and with /excludesticker.
+func (w *worker) loadStickerSet(ctx context.Context, stickerSetConfig StickerSetConfig) (stickers []sticker, _ error) {
 	stickerSet, err := w.api.GetStickerSet(&telego.GetStickerSetParams{
 		Name: stickerSetConfig.Name,
 	})
@@ -45,10 +126,86 @@ func (w *worker) loadStickerSet(stickerSetConfig StickerSetConfig) (stickerFileI
 		return nil, fmt.Errorf("get sticker set: %w", err)
 	}
 
-	for _, sticker := range stickerSet.Stickers {
-		if !slices.Contains(stickerSetConfig.ExcludeStickerIDs, sticker.FileID) {
-			stickerFileIDs = append(stickerFileIDs, sticker.FileID)
+	managed, err := w.managedStickerSets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get managed sticker sets: %w", err)
+	}
+	excluded := slices.Concat(stickerSetConfig.ExcludeStickerIDs, managed.excluded[stickerSetConfig.Name])
+
+	for _, s := range stickerSet.Stickers {
+		if slices.Contains(excluded, s.FileID) || slices.Contains(excluded, s.FileUniqueID) {
+			continue
+		}
+
+		weight, ok := stickerSetConfig.StickerWeights[s.FileID]
+		if !ok {
+			weight, ok = stickerSetConfig.StickerWeights[s.FileUniqueID]
+		}
+		if !ok {
+			weight = 1
+		}
+		if weight == 0 {
+			continue
+		}
+
+		stickers = append(stickers, sticker{
+			fileID:   s.FileID,
+			uniqueID: s.FileUniqueID,
+			emoji:    s.Emoji,
+			weight:   weight,
+		})
+	}
+	return stickers, nil
+}
+
+func (w *worker) recentStickers(chatID int64) []string {
+	recent, ok := w.cache.Get(recentStickersCacheKey(chatID))
+	if !ok {
+		return nil
+	}
+	return recent.([]string)
+}
+
+func (w *worker) rememberSticker(chatID int64, uniqueID string) {
+	avoidRepeats := w.config().Stickers.AvoidRepeats
+	if avoidRepeats <= 0 {
+		return
+	}
+
+	recent := append(slices.Clone(w.recentStickers(chatID)), uniqueID)
+	if len(recent) > avoidRepeats {
+		recent = recent[len(recent)-avoidRepeats:]
+	}
+	w.cache.SetDefault(recentStickersCacheKey(chatID), recent)
+}
+
+// recordStickerUsage is called after the sticker is sent.
+func (w *worker) recordStickerUsage(ctx context.Context, chatID int64, s pickedSticker) {
+	w.rememberSticker(chatID, s.uniqueID)
+	if err := w.db.IncreaseStickerUsage(ctx, s.setName, s.uniqueID); err != nil {
+		w.log.ErrorContext(ctx, "failed to record sticker usage", "error", err)
+	}
+}
+
+// pickWeighted returns a random item with probability proportional to its weight.
+// It returns false if there are no items with positive weight.
+func pickWeighted[T any](rng *rand.Rand, items []T, weight func(T) int) (T, bool) {
+	totalWeight := 0
+	for _, item := range items {
+		totalWeight += max(weight(item), 0)
+	}
+	if totalWeight == 0 {
+		var zero T
+		return zero, false
+	}
+
+	n := rng.IntN(totalWeight)
+	for _, item := range items {
+		w := max(weight(item), 0)
+		if n < w {
+			return item, true
 		}
+		n -= w
 	}
-	return stickerFileIDs, nil
+	panic("unreachable")
 }
@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
+)
+
+// managedStickerSets are sticker sets added, removed and filtered with admin commands.
+type managedStickerSets struct {
+	sets []db.StickerSet
+	// excluded are file unique IDs of excluded stickers by set name.
+	excluded map[string][]string
+}
+
+const managedStickerSetsCacheKey = "managed_sticker_sets"
+
+// addStickerSetTimeout is how long the bot waits for a sticker after /addstickerset without arguments.
+const addStickerSetTimeout = 5 * time.Minute
+
+func addingStickerSetCacheKey(chatID, userID int64) string {
+	return fmt.Sprintf("adding_sticker_set:%d:%d", chatID, userID)
+}
+
+func (w *worker) managedStickerSets(ctx context.Context) (managedStickerSets, error) {
+	v, err, _ := w.getStickerSetG.Do(managedStickerSetsCacheKey, func() (any, error) {
+		managed, ok := w.cache.Get(managedStickerSetsCacheKey)
+		if ok {
+			return managed, nil
+		}
+
+		sets, err := w.db.GetStickerSets(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get sticker sets: %w", err)
+		}
+		excluded, err := w.db.GetExcludedStickers(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get excluded stickers: %w", err)
+		}
+
+		managed = managedStickerSets{sets: sets, excluded: excluded}
+		w.cache.SetDefault(managedStickerSetsCacheKey, managed)
+		return managed, nil
+	})
+	if err != nil {
+		return managedStickerSets{}, err
+	}
+	return v.(managedStickerSets), nil
+}
+
+// stickerSets returns sticker sets from config merged with the ones added and removed
+// with admin commands.
+func (w *worker) stickerSets(ctx context.Context) ([]StickerSetConfig, error) {
+	managed, err := w.managedStickerSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make(map[string]bool, len(managed.sets))
+	for _, set := range managed.sets {
+		enabled[set.Name] = set.Enabled
+	}
+
+	config := w.config()
+	stickerSets := make([]StickerSetConfig, 0, len(config.StickerSets)+len(managed.sets))
+	for _, stickerSet := range config.StickerSets {
+		if isEnabled, ok := enabled[stickerSet.Name]; !ok || isEnabled {
+			stickerSets = append(stickerSets, stickerSet)
+		}
+	}
+	for _, set := range managed.sets {
+		if set.Enabled && !config.hasStickerSet(set.Name) {
+			stickerSets = append(stickerSets, StickerSetConfig{Name: set.Name})
+		}
+	}
+	return stickerSets, nil
+}
+
+// stickerSetChanged drops cached data of the set so that the change is applied right away.
+func (w *worker) stickerSetChanged(name string) {
+	w.cache.Delete(managedStickerSetsCacheKey)
+	w.cache.Delete(stickerSetCacheKey(name))
+}
+
+// stickerSetName accepts both set names and links like https://t.me/addstickers/name.
+func stickerSetName(arg string) string {
+	_, name, found := strings.Cut(arg, "/addstickers/")
+	if !found {
+		return arg
+	}
+	return name
+}
+
+func repliedSticker(msg *telego.Message) *telego.Sticker {
+	if msg.ReplyToMessage == nil {
+		return nil
+	}
+	return msg.ReplyToMessage.Sticker
+}
+
+func (w *worker) handleAddStickerSetRequest(ctx context.Context, msg *telego.Message) error {
+	args := commandArgs(msg)
+	switch {
+	case len(args) > 1:
+		return w.reply(ctx, msg, texts.StickerSetAddUsage, nil)
+	case len(args) == 1:
+		return w.addStickerSet(ctx, msg, stickerSetName(args[0]))
+	case repliedSticker(msg) != nil:
+		return w.addStickerSet(ctx, msg, repliedSticker(msg).SetName)
+	default:
+		w.cache.Set(addingStickerSetCacheKey(msg.Chat.ID, msg.From.ID), struct{}{}, addStickerSetTimeout)
+		return w.reply(ctx, msg, texts.StickerSetSendSticker, nil)
+	}
+}
+
+// handleSticker adds the set of the sticker if the sender has called /addstickerset
+// without arguments shortly before.
+func (w *worker) handleSticker(ctx context.Context, msg *telego.Message) error {
+	key := addingStickerSetCacheKey(msg.Chat.ID, msg.From.ID)
+	if _, ok := w.cache.Get(key); !ok {
+		if source := w.aiReplySource(msg); source != "" {
+			return w.handleAIReply(ctx, msg, source)
+		}
+		return nil
+	}
+	w.cache.Delete(key)
+
+	return w.addStickerSet(ctx, msg, msg.Sticker.SetName)
+}
+
+func (w *worker) addStickerSet(ctx context.Context, msg *telego.Message, name string) error {
+	if name == "" {
+		return w.reply(ctx, msg, texts.StickerSetNoName, nil)
+	}
+
+	stickerSet, err := w.api.GetStickerSet(&telego.GetStickerSetParams{Name: name})
+	if err != nil {
+		w.log.WarnContext(ctx, "failed to get sticker set", "name", name, "error", err)
+		return w.reply(ctx, msg, texts.StickerSetNotFound, map[string]any{"Name": name})
+	}
+
+	if err := w.db.SetStickerSetEnabled(ctx, stickerSet.Name, true); err != nil {
+		return fmt.Errorf("enable sticker set: %w", err)
+	}
+	w.stickerSetChanged(stickerSet.Name)
+	w.log.InfoContext(ctx, "added sticker set", "name", stickerSet.Name)
+
+	return w.reply(ctx, msg, texts.StickerSetAdded, map[string]any{
+		"Name":  stickerSet.Name,
+		"Count": len(stickerSet.Stickers),
+	})
+}
+
+func (w *worker) handleRemoveStickerSetRequest(ctx context.Context, msg *telego.Message) error {
+	var name string
+	switch args := commandArgs(msg); {
+	case len(args) == 1:
+		name = stickerSetName(args[0])
+	case len(args) == 0 && repliedSticker(msg) != nil:
+		name = repliedSticker(msg).SetName
+	default:
+		return w.reply(ctx, msg, texts.StickerSetRemoveUsage, nil)
+	}
+
+	managed, err := w.managedStickerSets(ctx)
+	if err != nil {
+		return fmt.Errorf("get managed sticker sets: %w", err)
+	}
+	stored := slices.ContainsFunc(managed.sets, func(set db.StickerSet) bool {
+		return set.Name == name
+	})
+
+	switch {
+	case w.config().hasStickerSet(name):
+		// Sets from config are kept disabled, otherwise they would be used again.
+		err = w.db.SetStickerSetEnabled(ctx, name, false)
+	case stored:
+		err = w.db.DeleteStickerSet(ctx, name)
+	default:
+		return w.reply(ctx, msg, texts.StickerSetUnknown, map[string]any{"Name": name})
+	}
+	if err != nil {
+		return fmt.Errorf("remove sticker set: %w", err)
+	}
+	w.stickerSetChanged(name)
+	w.log.InfoContext(ctx, "removed sticker set", "name", name)
+
+	return w.reply(ctx, msg, texts.StickerSetRemoved, map[string]any{"Name": name})
+}
+
+func (w *worker) handleExcludeStickerRequest(ctx context.Context, msg *telego.Message) error {
+	sticker := repliedSticker(msg)
+	if sticker == nil {
+		return w.reply(ctx, msg, texts.StickerExcludeUsage, nil)
+	}
+	if sticker.SetName == "" {
+		return w.reply(ctx, msg, texts.StickerSetNoName, nil)
+	}
+
+	if err := w.db.ExcludeSticker(ctx, sticker.SetName, sticker.FileUniqueID); err != nil {
+		return fmt.Errorf("exclude sticker: %w", err)
+	}
+	w.stickerSetChanged(sticker.SetName)
+	w.log.InfoContext(ctx, "excluded sticker", "setName", sticker.SetName, "stickerId", sticker.FileUniqueID)
+
+	return w.reply(ctx, msg, texts.StickerExcluded, map[string]any{"Name": sticker.SetName})
+}
+
+func (w *worker) handleStickerSetsRequest(ctx context.Context, msg *telego.Message) error {
+	stickerSets, err := w.stickerSets(ctx)
+	if err != nil {
+		return fmt.Errorf("get sticker sets: %w", err)
+	}
+	if len(stickerSets) == 0 {
+		return w.reply(ctx, msg, texts.StickerSetsEmpty, nil)
+	}
+
+	usage, err := w.db.GetStickerSetUsage(ctx)
+	if err != nil {
+		return fmt.Errorf("get sticker set usage: %w", err)
+	}
+
+	config := w.config()
+	lines := make([]string, 0, len(stickerSets))
+	for _, stickerSet := range stickerSets {
+		stickers, err := w.getStickerSet(ctx, stickerSet)
+		if err != nil {
+			w.log.WarnContext(ctx, "failed to get sticker set", "name", stickerSet.Name, "error", err)
+		}
+		lines = append(lines, w.render(ctx, msg, texts.StickerSetsLine, map[string]any{
+			"Name":       stickerSet.Name,
+			"Loaded":     err == nil,
+			"Count":      len(stickers),
+			"Usage":      usage[stickerSet.Name],
+			"Configured": config.hasStickerSet(stickerSet.Name),
+		}))
+	}
+
+	_, err = w.api.SendMessage(simpleReply(strings.Join(lines, "\n"), msg))
+	if err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/db/postgres"
+)
+
+// OpenStorage opens the storage backend selected in config.
+// Sqlite database is opened at dbPath rather than at config.SqlitePath,
+// so that the path can be overridden.
+func OpenStorage(ctx context.Context, config *Config, dbPath string) (db.Storage, error) {
+	switch config.StorageDriver {
+	case StorageSqlite:
+		return db.NewDB(dbPath)
+	case StoragePostgres:
+		return postgres.NewDB(ctx, config.PostgresDSN)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", config.StorageDriver)
+	}
+}
+
+func (b *Bot) openStorage(ctx context.Context) (db.Storage, error) {
+	return OpenStorage(ctx, b.config(), b.dbPath)
+}
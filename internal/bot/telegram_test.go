@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/logging"
+)
+
+const testToken = "123456789:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+// fakeTelegram serves the Bot API and records names of called methods. Methods are
+// answered by handlers keyed by method name, and files by the "file" handler. Other
+// send methods return a message, and the rest return true.
+type fakeTelegram struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func newFakeTelegram(t *testing.T, handlers map[string]http.HandlerFunc) (*telego.Bot, *fakeTelegram) {
+	t.Helper()
+
+	fake := &fakeTelegram{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := strings.CutPrefix(r.URL.Path, "/file/bot"+testToken+"/"); ok {
+			if handler, ok := handlers["file"]; ok && path != "" {
+				handler(w, r)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+
+		method, ok := strings.CutPrefix(r.URL.Path, "/bot"+testToken+"/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fake.mu.Lock()
+		fake.calls = append(fake.calls, method)
+		fake.mu.Unlock()
+
+		if handler, ok := handlers[method]; ok {
+			handler(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(method, "send") {
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":2,"date":0,"chat":{"id":1,"type":"group"}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	api, err := telego.NewBot(testToken, telego.WithAPIServer(server.URL), telego.WithDiscardLogger())
+	if err != nil {
+		t.Fatalf("new bot: %s", err)
+	}
+	return api, fake
+}
+
+func (f *fakeTelegram) called() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// newTestWorker returns a worker with the default config and an empty database, talking to api.
+func newTestWorker(t *testing.T, api *telego.Bot) *worker {
+	t.Helper()
+
+	config := &Config{}
+	config.SetDefaults()
+	st, err := newState(config)
+	if err != nil {
+		t.Fatalf("new state: %s", err)
+	}
+	var current atomic.Pointer[state]
+	current.Store(st)
+
+	storage, err := db.NewDB(filepath.Join(t.TempDir(), "svoi.db"))
+	if err != nil {
+		t.Fatalf("new db: %s", err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+
+	return &worker{
+		state:     &current,
+		rng:       rand.New(rand.NewPCG(1, 2)),
+		api:       api,
+		cache:     cache.New(time.Hour, time.Hour),
+		windowsMu: &sync.Mutex{},
+		db:        storage,
+		log:       logging.New("test"),
+	}
+}
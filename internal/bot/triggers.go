@@ -2,49 +2,34 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand/v2"
-	"regexp"
-	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/mymmrac/telego"
 
 	"github.com/LeKSuS-04/svoi-bot/internal/ai"
+	"github.com/LeKSuS-04/svoi-bot/internal/detector"
+	"github.com/LeKSuS-04/svoi-bot/internal/strategy"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
 )
 
 type triggerType string
 type responseType string
 
 const (
-	svo triggerType = "svo"
-	zov triggerType = "zov"
+	svo = triggerType(detector.Svo)
+	zov = triggerType(detector.Zov)
 
 	regular      responseType = "regular"
 	likvidirovan responseType = "likvidirovan"
 	aiGenerated  responseType = "ai_generated"
-
-	svoRegexp = "[сСsScC][вВvVB8][оОoO0]+"
-	zovRegexp = "[зЗzZ3][оОoO0]+[8вВvVB]"
+	reaction     responseType = "reaction"
 )
 
-type matcher struct {
-	typ triggerType
-	re  *regexp.Regexp
-}
-
-var matchers = []matcher{
-	{
-		typ: svo,
-		re:  regexp.MustCompile(svoRegexp),
-	},
-	{
-		typ: zov,
-		re:  regexp.MustCompile(zovRegexp),
-	},
-}
-
 type trigger struct {
+	// position is an offset of the quote in UTF-16 code units, as expected by Telegram.
 	position int
 	quote    string
 
@@ -74,10 +59,12 @@ func (t *triggerResponseBase) responseType() responseType {
 type textResponse struct {
 	triggerResponseBase
 	text string
+	// sent is called with the sent message.
+	sent func(msg *telego.Message)
 }
 
 func (t *textResponse) sendReply(api *telego.Bot, chatID telego.ChatID, replyParams *telego.ReplyParameters) error {
-	_, err := api.SendMessage(
+	msg, err := api.SendMessage(
 		&telego.SendMessageParams{
 			Text:            t.text,
 			ChatID:          chatID,
@@ -87,12 +74,17 @@ func (t *textResponse) sendReply(api *telego.Bot, chatID telego.ChatID, replyPar
 	if err != nil {
 		return fmt.Errorf("send message: %w", err)
 	}
+	if t.sent != nil {
+		t.sent(msg)
+	}
 	return nil
 }
 
 type stickerResponse struct {
 	triggerResponseBase
 	fileID string
+	// sent is called after the sticker is sent.
+	sent func()
 }
 
 func (s *stickerResponse) sendReply(api *telego.Bot, chatID telego.ChatID, replyParams *telego.ReplyParameters) error {
@@ -108,27 +100,108 @@ func (s *stickerResponse) sendReply(api *telego.Bot, chatID telego.ChatID, reply
 	if err != nil {
 		return fmt.Errorf("send sticker: %w", err)
 	}
+	if s.sent != nil {
+		s.sent()
+	}
 	return nil
 }
 
-func (w *worker) generateTriggerResponse(ctx context.Context, trigger trigger, msg *telego.Message) (triggerResponse, error) {
-	rng := rand.IntN(100)
+type reactionResponse struct {
+	triggerResponseBase
+	reaction strategy.Reaction
+}
 
-	allowAI := true
-	if w.ai == nil {
-		allowAI = false
-	} else if _, ok := w.cache.Get(aiSenderKey(msg.From.ID)); ok {
-		allowAI = false
+func (r *reactionResponse) sendReply(api *telego.Bot, chatID telego.ChatID, replyParams *telego.ReplyParameters) error {
+	var reactionType telego.ReactionType = &telego.ReactionTypeEmoji{
+		Type:  telego.ReactionEmoji,
+		Emoji: r.reaction.Emoji,
+	}
+	if r.reaction.CustomEmojiID != "" {
+		reactionType = &telego.ReactionTypeCustomEmoji{
+			Type:          telego.ReactionCustomEmoji,
+			CustomEmojiID: r.reaction.CustomEmojiID,
+		}
 	}
 
-	switch {
-	case rng == 0:
-		return w.makeLikvidirovanResponse(trigger), nil
+	err := api.SetMessageReaction(&telego.SetMessageReactionParams{
+		ChatID:    chatID,
+		MessageID: replyParams.MessageID,
+		Reaction:  []telego.ReactionType{reactionType},
+	})
+	if err != nil {
+		return fmt.Errorf("set message reaction: %w", err)
+	}
+	return nil
+}
+
+// aiResponse is a response that is yet to be generated. Instead of being sent right away,
+// it is scheduled with scheduleAIReply, and the generated response is sent once it is ready.
+type aiResponse struct {
+	triggerResponseBase
+	persona     string
+	prompt      string
+	userContext ai.UserContext
+	// history is the conversation the response continues.
+	history []ai.Turn
+	// imageFileID is the file ID of the image passed to the AI along with the prompt.
+	imageFileID string
+	// source is why the message is addressed to the bot, empty for responses to triggers.
+	source AIReplySource
+}
+
+func (r *aiResponse) sendReply(*telego.Bot, telego.ChatID, *telego.ReplyParameters) error {
+	return errors.New("ai responses must be scheduled, not sent")
+}
+
+func (w *worker) generateTriggerResponse(ctx context.Context, trigger trigger, msg *telego.Message) (triggerResponse, error) {
+	body, _ := messageText(msg)
+	option, ok, err := w.strategy().Choose(w.rng, strategy.Input{
+		TriggerType:   detector.Type(trigger.typ),
+		ChatID:        msg.Chat.ID,
+		Time:          time.Unix(int64(msg.Date), 0),
+		MessageLength: utf8.RuneCountInString(body),
+		UserTriggerCount: func() (int, error) {
+			stats, err := w.db.GetUserStats(ctx, int(msg.From.ID), int(msg.Chat.ID))
+			if err != nil {
+				return 0, err
+			}
+			return stats.SvoCount + stats.ZovCount, nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("choose response: %w", err)
+	}
+	if !ok {
+		return w.makeDefaultResponse(ctx, trigger, msg), nil
+	}
+	w.log.DebugContext(ctx, "chose response", "kind", option.Kind, "trigger", trigger.typ)
+
+	switch option.Kind {
+	case strategy.KindLikvidirovan:
+		return w.makeLikvidirovanResponse(ctx, trigger, msg), nil
+
+	case strategy.KindSticker:
+		return w.makeStickerResponse(ctx, trigger, msg), nil
+
+	case strategy.KindText:
+		text, err := w.texts().RenderText(w.rng, w.language(ctx, msg), option.Text, triggerTextData(msg, trigger))
+		if err != nil {
+			return nil, fmt.Errorf("render text: %w", err)
+		}
+		return &textResponse{
+			triggerResponseBase: triggerResponseBase{
+				t: trigger, typ: regular,
+			},
+			text: text,
+		}, nil
 
-	case rng < 20:
-		return w.makeStickerResponse(trigger), nil
+	case strategy.KindAnimation, strategy.KindVoice, strategy.KindAudio, strategy.KindPhoto:
+		return w.makeMediaResponse(ctx, trigger, msg, option.Kind), nil
 
-	case rng >= 60 && allowAI:
+	case strategy.KindReaction:
+		return w.makeReactionResponse(trigger, option.Reactions), nil
+
+	case strategy.KindAI:
 		resp, err := w.makeAIResponse(ctx, trigger, msg)
 		if err != nil {
 			return nil, fmt.Errorf("make ai response: %w", err)
@@ -136,72 +209,88 @@ func (w *worker) generateTriggerResponse(ctx context.Context, trigger trigger, m
 		return resp, nil
 
 	default:
-		return w.makeDefaultResponse(trigger), nil
+		return w.makeDefaultResponse(ctx, trigger, msg), nil
 	}
 }
 
-func (w *worker) makeDefaultResponse(trigger trigger) triggerResponse {
+func (w *worker) makeDefaultResponse(ctx context.Context, trigger trigger, msg *telego.Message) triggerResponse {
 	return &textResponse{
 		triggerResponseBase: triggerResponseBase{
 			t: trigger, typ: regular,
 		},
-		text: "Г" + strings.Repeat("О", 3+rand.IntN(10)) + "Л",
+		text: w.render(ctx, msg, texts.Goal, triggerTextData(msg, trigger)),
 	}
 }
 
-func (w *worker) makeLikvidirovanResponse(trigger trigger) triggerResponse {
+func (w *worker) makeLikvidirovanResponse(ctx context.Context, trigger trigger, msg *telego.Message) triggerResponse {
 	return &textResponse{
 		triggerResponseBase: triggerResponseBase{
 			t: trigger, typ: likvidirovan,
 		},
-		text: "ЛИКВИДИРОВАН",
+		text: w.render(ctx, msg, texts.Likvidirovan, triggerTextData(msg, trigger)),
 	}
 }
 
-func (w *worker) makeStickerResponse(trigger trigger) triggerResponse {
-	fileID, err := w.getSticker()
+func (w *worker) makeStickerResponse(ctx context.Context, trigger trigger, msg *telego.Message) triggerResponse {
+	sticker, err := w.getSticker(ctx, msg.Chat.ID, detector.Type(trigger.typ))
 	if err != nil {
-		return w.makeDefaultResponse(trigger)
+		w.log.ErrorContext(ctx, "failed to get sticker", "error", err)
+		return w.makeDefaultResponse(ctx, trigger, msg)
 	}
 	return &stickerResponse{
 		triggerResponseBase: triggerResponseBase{
 			t: trigger, typ: regular,
 		},
-		fileID: fileID,
+		fileID: sticker.fileID,
+		sent: func() {
+			w.recordStickerUsage(ctx, msg.Chat.ID, sticker)
+		},
+	}
+}
+
+func (w *worker) makeReactionResponse(trigger trigger, reactions []strategy.Reaction) triggerResponse {
+	if len(reactions) == 0 {
+		reactions = strategy.DefaultReactions
+	}
+	return &reactionResponse{
+		triggerResponseBase: triggerResponseBase{
+			t: trigger, typ: reaction,
+		},
+		reaction: reactions[w.rng.IntN(len(reactions))],
 	}
 }
 
 func (w *worker) makeAIResponse(ctx context.Context, trigger trigger, msg *telego.Message) (triggerResponse, error) {
 	if w.ai == nil {
-		return w.makeDefaultResponse(trigger), nil
+		return w.makeDefaultResponse(ctx, trigger, msg), nil
 	}
 
 	if _, ok := w.cache.Get(aiSenderKey(msg.From.ID)); ok {
-		return w.makeDefaultResponse(trigger), nil
+		return w.makeDefaultResponse(ctx, trigger, msg), nil
 	}
 
-	if !isAIRespondable(msg.Text) {
-		return w.makeDefaultResponse(trigger), nil
+	text, _ := messageText(msg)
+	if !w.detector().IsAIRespondable(text) {
+		return w.makeDefaultResponse(ctx, trigger, msg), nil
 	}
 
-	w.log.InfoContext(ctx, "generating ai response", "text", msg.Text)
+	w.log.InfoContext(ctx, "generating ai response", "text", text)
 
-	if err := w.cache.Add(aiSenderKey(msg.From.ID), struct{}{}, w.config.AI.ResponseResetPeriod); err != nil {
+	if err := w.cache.Add(aiSenderKey(msg.From.ID), struct{}{}, w.config().AI.ResponseResetPeriod); err != nil {
 		w.log.ErrorContext(ctx, "failed to add to cache", "error", err)
-		return w.makeDefaultResponse(trigger), nil
-	}
-
-	resp, err := w.ai.GeneratePatrioticResponse(ctx, msg.Text, makeAIContext(msg))
-	if err != nil {
-		w.cache.Delete(aiSenderKey(msg.From.ID))
-		return nil, fmt.Errorf("generate patriotic response: %w", err)
+		return w.makeDefaultResponse(ctx, trigger, msg), nil
 	}
 
-	return &textResponse{
+	// The persona is picked now, so that the image is only downloaded if its model accepts it.
+	persona := w.config().AI.PickPersona(w.persona(ctx, msg.Chat.ID))
+	return &aiResponse{
 		triggerResponseBase: triggerResponseBase{
 			t: trigger, typ: aiGenerated,
 		},
-		text: resp,
+		persona:     persona,
+		prompt:      text,
+		userContext: makeAIContext(msg),
+		imageFileID: w.aiImageFileID(msg, persona),
 	}, nil
 }
 
@@ -213,54 +302,31 @@ func makeAIContext(msg *telego.Message) ai.UserContext {
 	}
 }
 
-func isAIRespondable(msg string) bool {
-	if msg == "" {
-		return false
-	}
-
-	words := strings.Fields(msg)
-	if len(words) < 5 {
-		return false
+func triggerTextData(msg *telego.Message, trigger trigger) texts.Trigger {
+	return texts.Trigger{
+		User:  messageUser(msg),
+		Quote: trigger.quote,
+		Type:  string(trigger.typ),
 	}
+}
 
-	exactSvoPattern := regexp.MustCompile("^" + svoRegexp + "$")
-	svoPattern := regexp.MustCompile(svoRegexp)
-	exactZovPattern := regexp.MustCompile("^" + zovRegexp + "$")
-	zovPattern := regexp.MustCompile(zovRegexp)
-
-	patternCount := 0
-	containsPatternCount := 0
-	normalWordCount := 0
-
-	for _, word := range words {
-		lowercaseWord := strings.ToLower(word)
-
-		if exactSvoPattern.MatchString(lowercaseWord) ||
-			exactZovPattern.MatchString(lowercaseWord) {
-			patternCount++
-		} else if svoPattern.MatchString(lowercaseWord) ||
-			zovPattern.MatchString(lowercaseWord) {
-			containsPatternCount++
-		} else {
-			normalWordCount++
-		}
+func messageUser(msg *telego.Message) texts.User {
+	return texts.User{
+		ID:        msg.From.ID,
+		Username:  msg.From.Username,
+		FirstName: msg.From.FirstName,
+		LastName:  msg.From.LastName,
 	}
-
-	return normalWordCount >= 5 && patternCount <= 2 && containsPatternCount <= 2
 }
 
-func findTriggers(text string) (triggers []trigger) {
-	for _, matcher := range matchers {
-		matches := matcher.re.FindAllStringIndex(text, -1)
-		for _, match := range matches {
-			quote := text[match[0]:match[1]]
-			triggers = append(triggers, trigger{
-				quote:      quote,
-				position:   utf8.RuneCountInString(text[:match[0]]),
-				runeLength: utf8.RuneCountInString(quote),
-				typ:        matcher.typ,
-			})
-		}
+func findTriggers(d *detector.Detector, text string) (triggers []trigger) {
+	for _, match := range d.Find(text) {
+		triggers = append(triggers, trigger{
+			quote:      match.Quote,
+			position:   match.UTF16Offset,
+			runeLength: match.RuneLength,
+			typ:        triggerType(match.Type),
+		})
 	}
 	return triggers
 }
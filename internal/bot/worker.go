@@ -2,32 +2,94 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
 	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoapi"
 	"github.com/patrickmn/go-cache"
 	"golang.org/x/sync/singleflight"
 
 	"github.com/LeKSuS-04/svoi-bot/internal/ai"
 	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/detector"
 	"github.com/LeKSuS-04/svoi-bot/internal/logging"
+	"github.com/LeKSuS-04/svoi-bot/internal/strategy"
+	"github.com/LeKSuS-04/svoi-bot/internal/texts"
 )
 
 type worker struct {
-	config         *Config
+	state          *atomic.Pointer[state]
+	rng            *rand.Rand
 	api            *telego.Bot
+	botID          int64
 	botUsername    string
+	botMention     *regexp.Regexp
 	getStickerSetG *singleflight.Group
 	cache          *cache.Cache
-	db             *db.DB
-	ai             *ai.AI
-	log            *slog.Logger
-	updates        <-chan telego.Update
+	// windowsMu guards sliding windows of spam detection, which are kept in cache.
+	windowsMu *sync.Mutex
+	db        db.Storage
+	backuper  *backuper
+	ai        *ai.AI
+	aiJobs    *aiJobPool
+	log       *slog.Logger
+	updates   <-chan telego.Update
+}
+
+func (w *worker) config() *Config {
+	return w.state.Load().config
+}
+
+func (w *worker) detector() *detector.Detector {
+	return w.state.Load().detector
+}
+
+func (w *worker) strategy() *strategy.Engine {
+	return w.state.Load().strategy
+}
+
+func (w *worker) texts() *texts.Library {
+	return w.state.Load().texts
+}
+
+func (w *worker) media() *mediaLibrary {
+	return w.state.Load().media
+}
+
+// render renders a message from the text library in the language picked for msg,
+// falling back to the built-in phrases if a configured one fails.
+func (w *worker) render(ctx context.Context, msg *telego.Message, name texts.Name, data any) string {
+	lang := w.language(ctx, msg)
+	text, err := w.texts().Render(w.rng, lang, name, data)
+	if err == nil {
+		return text
+	}
+	w.log.ErrorContext(ctx, "failed to render text, using default", "name", name, "language", lang, "error", err)
+
+	text, err = texts.RenderDefault(w.rng, lang, name, data)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to render default text", "name", name, "language", lang, "error", err)
+	}
+	return text
+}
+
+// reply renders a message and sends it in reply to msg.
+func (w *worker) reply(ctx context.Context, msg *telego.Message, name texts.Name, data any) error {
+	_, err := w.api.SendMessage(simpleReply(w.render(ctx, msg, name, data), msg))
+	if err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+	return nil
 }
 
 func (w *worker) Work(ctx context.Context) {
@@ -103,7 +165,7 @@ func (w *worker) handleUpdate(ctx context.Context, update telego.Update) (err er
 }
 
 func (w *worker) handleMessage(ctx context.Context, msg *telego.Message) error {
-	w.log.DebugContext(ctx, "handling message", "content", msg.Text)
+	w.log.DebugContext(ctx, "handling message", "content", msg.Text, "caption", msg.Caption)
 
 	commands := []Command{
 		{
@@ -114,11 +176,57 @@ func (w *worker) handleMessage(ctx context.Context, msg *telego.Message) error {
 			Name:    "pwd",
 			Handler: w.handlePwdRequest,
 		},
+		{
+			Name:    "language",
+			Handler: w.handleLanguageRequest,
+		},
+		{
+			Name:    "spam",
+			Handler: w.handleSpamRequest,
+		},
+		{
+			Name:    "persona",
+			Handler: w.handlePersonaRequest,
+		},
+		{
+			Name:    "aireplies",
+			Handler: w.handleAIRepliesRequest,
+		},
 		{
 			Name:      "broadcast",
 			Handler:   w.handleBroadcastRequest,
 			AdminOnly: true,
 		},
+		{
+			Name:      "exportstats",
+			Handler:   w.handleExportStatsRequest,
+			AdminOnly: true,
+		},
+		{
+			Name:      "backup",
+			Handler:   w.handleBackupRequest,
+			AdminOnly: true,
+		},
+		{
+			Name:      "stickersets",
+			Handler:   w.handleStickerSetsRequest,
+			AdminOnly: true,
+		},
+		{
+			Name:      "addstickerset",
+			Handler:   w.handleAddStickerSetRequest,
+			AdminOnly: true,
+		},
+		{
+			Name:      "removestickerset",
+			Handler:   w.handleRemoveStickerSetRequest,
+			AdminOnly: true,
+		},
+		{
+			Name:      "excludesticker",
+			Handler:   w.handleExcludeStickerRequest,
+			AdminOnly: true,
+		},
 	}
 
 	for _, command := range commands {
@@ -131,6 +239,9 @@ func (w *worker) handleMessage(ctx context.Context, msg *telego.Message) error {
 		}
 	}
 
+	if msg.Sticker != nil {
+		return w.handleSticker(ctx, msg)
+	}
 	return w.handleRegularMessage(ctx, msg)
 }
 
@@ -143,8 +254,13 @@ func (w *worker) handleRegularMessage(ctx context.Context, msg *telego.Message)
 		UserDisplayName: userDisplayedName,
 	}
 
-	triggers := findTriggers(msg.Text)
+	// Quotes of triggers found in captions are positioned within the caption.
+	text, _ := messageText(msg)
+	triggers := findTriggers(w.detector(), text)
 	if len(triggers) == 0 {
+		if source := w.aiReplySource(msg); source != "" {
+			return w.handleAIReply(ctx, msg, source)
+		}
 		return nil
 	}
 	w.log.DebugContext(ctx, "found triggers", "triggers", triggers)
@@ -160,7 +276,7 @@ func (w *worker) handleRegularMessage(ctx context.Context, msg *telego.Message)
 		return fmt.Errorf("make responses: %w", err)
 	}
 
-	if err = w.sendReplies(msg, replies); err != nil {
+	if err = w.sendReplies(ctx, msg, replies); err != nil {
 		return fmt.Errorf("send replies: %w", err)
 	}
 
@@ -171,33 +287,6 @@ func (w *worker) handleRegularMessage(ctx context.Context, msg *telego.Message)
 	return nil
 }
 
-func (w *worker) preventSpam(ctx context.Context, msg *telego.Message, triggers []trigger) (bool, error) {
-	triggerCount := len(triggers)
-	triggersLength := 0
-	for _, trigger := range triggers {
-		triggersLength += trigger.runeLength
-	}
-	textLength := utf8.RuneCountInString(msg.Text)
-	spam := tooManyTriggers(triggerCount, triggersLength, textLength)
-
-	w.log.DebugContext(ctx, "checking for spam",
-		slog.Int("triggerCount", triggerCount),
-		slog.Int("triggersLength", triggersLength),
-		slog.Int("textLength", textLength),
-		slog.Bool("isSpam", spam),
-	)
-
-	if spam {
-		response := simpleReply("Спамер", msg)
-		_, err := w.api.SendMessage(response)
-		if err != nil {
-			return false, fmt.Errorf("send message: %w", err)
-		}
-		return true, nil
-	}
-	return false, nil
-}
-
 type reply struct {
 	response triggerResponse
 	trigger  trigger
@@ -219,7 +308,7 @@ func (w *worker) makeReplies(ctx context.Context, msg *telego.Message, triggers
 		rsp, err := w.generateTriggerResponse(ctx, trigger, msg)
 		if err != nil {
 			w.log.ErrorContext(ctx, "failed to generate response", "error", err, "trigger", trigger, "msg", msg)
-			rsp = w.makeDefaultResponse(trigger)
+			rsp = w.makeDefaultResponse(ctx, trigger, msg)
 		}
 
 		// Only answer with AI-generated responses
@@ -248,28 +337,66 @@ func (w *worker) makeReplies(ctx context.Context, msg *telego.Message, triggers
 	return replies, nil
 }
 
-func (w *worker) sendReplies(msg *telego.Message, replies []reply) error {
+func (w *worker) sendReplies(ctx context.Context, msg *telego.Message, replies []reply) error {
+	reacted := false
 	for _, r := range replies {
+		if pending, ok := r.response.(*aiResponse); ok {
+			if err := w.scheduleAIReply(ctx, msg, r.trigger, pending); err != nil {
+				return fmt.Errorf("schedule ai reply: %w", err)
+			}
+			continue
+		}
+
+		response := r.response
+		_, rateLimited := w.cache.Get(rateLimitedChatKey(msg.Chat.ID))
+		if rateLimited {
+			response = w.makeReactionResponse(r.trigger, w.strategy().FallbackReactions())
+		}
+		// Bots can put only one reaction on a message, so the rest would just replace it.
+		if reacted && response.responseType() == reaction {
+			continue
+		}
+
+		replyParams := &telego.ReplyParameters{
+			MessageID:     msg.MessageID,
+			Quote:         r.trigger.quote,
+			QuotePosition: r.trigger.position,
+		}
+		err := response.sendReply(w.api, msg.Chat.ChatID(), replyParams)
+
+		var apiErr *telegoapi.Error
+		if errors.As(err, &apiErr) && apiErr.ErrorCode == http.StatusTooManyRequests && response.responseType() != reaction {
+			retryAfter := defaultRateLimitPeriod
+			if apiErr.Parameters != nil && apiErr.Parameters.RetryAfter > 0 {
+				retryAfter = time.Duration(apiErr.Parameters.RetryAfter) * time.Second
+			}
+			w.log.WarnContext(ctx, "chat is rate-limited, falling back to reactions", "retryAfter", retryAfter)
+			w.cache.Set(rateLimitedChatKey(msg.Chat.ID), struct{}{}, retryAfter)
+
+			response = w.makeReactionResponse(r.trigger, w.strategy().FallbackReactions())
+			err = response.sendReply(w.api, msg.Chat.ChatID(), replyParams)
+		}
+
 		responseTypeStatistics.
-			WithLabelValues(chatIdLabel(msg), string(r.response.responseType())).
+			WithLabelValues(chatIdLabel(msg), string(response.responseType())).
 			Inc()
-
-		err := r.response.sendReply(
-			w.api,
-			msg.Chat.ChatID(),
-			&telego.ReplyParameters{
-				MessageID:     msg.MessageID,
-				Quote:         r.trigger.quote,
-				QuotePosition: r.trigger.position,
-			},
-		)
 		if err != nil {
 			return fmt.Errorf("respond: %w", err)
 		}
+		if response.responseType() == reaction {
+			reacted = true
+		}
 	}
 	return nil
 }
 
+// defaultRateLimitPeriod is used when Telegram does not say how long to wait.
+const defaultRateLimitPeriod = 30 * time.Second
+
+func rateLimitedChatKey(chatID int64) string {
+	return fmt.Sprintf("rate_limited:%d", chatID)
+}
+
 func chatIdLabel(msg *telego.Message) string {
 	return strconv.FormatInt(msg.Chat.ID, 10)
 }
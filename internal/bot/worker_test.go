@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/strategy"
+)
+
+func testMessage() *telego.Message {
+	return &telego.Message{
+		MessageID: 1,
+		Chat:      telego.Chat{ID: 1, Type: telego.ChatTypeGroup},
+		From:      &telego.User{ID: 2, FirstName: "Иван"},
+	}
+}
+
+func TestSendRepliesReactsOnce(t *testing.T) {
+	reactionReply := func() reply {
+		return reply{response: &reactionResponse{
+			triggerResponseBase: triggerResponseBase{typ: reaction},
+			reaction:            strategy.Reaction{Emoji: "🔥"},
+		}}
+	}
+	textReply := reply{response: &textResponse{
+		triggerResponseBase: triggerResponseBase{typ: regular},
+		text:                "ГОООЛ",
+	}}
+
+	api, fake := newFakeTelegram(t, nil)
+	w := newTestWorker(t, api)
+	replies := []reply{reactionReply(), textReply, reactionReply()}
+	if err := w.sendReplies(context.Background(), testMessage(), replies); err != nil {
+		t.Fatalf("send replies: %s", err)
+	}
+
+	want := []string{"setMessageReaction", "sendMessage"}
+	if got := fake.called(); !slices.Equal(got, want) {
+		t.Errorf("called %v, want %v", got, want)
+	}
+}
+
+func TestSendRepliesRateLimited(t *testing.T) {
+	api, fake := newFakeTelegram(t, map[string]http.HandlerFunc{
+		"sendMessage": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":5}}`))
+		},
+	})
+	w := newTestWorker(t, api)
+	textReply := func() reply {
+		return reply{response: &textResponse{
+			triggerResponseBase: triggerResponseBase{typ: regular},
+			text:                "ГОООЛ",
+		}}
+	}
+	replies := []reply{textReply(), textReply(), textReply()}
+	if err := w.sendReplies(context.Background(), testMessage(), replies); err != nil {
+		t.Fatalf("send replies: %s", err)
+	}
+
+	want := []string{"sendMessage", "setMessageReaction"}
+	if got := fake.called(); !slices.Equal(got, want) {
+		t.Errorf("called %v, want %v", got, want)
+	}
+	if _, ok := w.cache.Get(rateLimitedChatKey(1)); !ok {
+		t.Error("chat is not marked as rate-limited")
+	}
+}
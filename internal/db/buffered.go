@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/logging"
+)
+
+type statsKey struct {
+	userID int
+	chatID int
+}
+
+// BufferedStorage aggregates stats increments in memory and periodically writes them
+// to the underlying storage in a single transaction. Reads flush pending increments
+// first, so that they are never stale.
+type BufferedStorage struct {
+	Storage
+
+	log     *slog.Logger
+	mu      sync.Mutex
+	pending map[statsKey]NamedStats
+	// flushMu is held for the whole flush, so that reads wait for increments that are being
+	// written by a concurrent flush. Reads that don't flush hold it for reading.
+	flushMu sync.RWMutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ Storage = (*BufferedStorage)(nil)
+
+func NewBufferedStorage(storage Storage, flushInterval time.Duration) *BufferedStorage {
+	b := &BufferedStorage{
+		Storage: storage,
+		log:     logging.New("buffered-storage"),
+		pending: make(map[statsKey]NamedStats),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run(flushInterval)
+	return b
+}
+
+func (b *BufferedStorage) run(flushInterval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := b.Flush(context.Background()); err != nil {
+			b.log.Error("failed to flush stats", "error", err)
+		}
+	}
+}
+
+func (b *BufferedStorage) IncreaseStats(_ context.Context, stats NamedStats) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.add(stats)
+	return nil
+}
+
+func (b *BufferedStorage) IncreaseStatsBatch(_ context.Context, batch []NamedStats) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, stats := range batch {
+		b.add(stats)
+	}
+	return nil
+}
+
+// add must be called with b.mu held.
+func (b *BufferedStorage) add(stats NamedStats) {
+	key := statsKey{userID: stats.UserID, chatID: stats.ChatID}
+	pending, ok := b.pending[key]
+	if !ok {
+		b.pending[key] = stats
+		return
+	}
+
+	pending.UserDisplayName = stats.UserDisplayName
+	pending.SvoCount += stats.SvoCount
+	pending.ZovCount += stats.ZovCount
+	pending.LikvidirovanCount += stats.LikvidirovanCount
+	b.pending[key] = pending
+}
+
+// restore puts back increments that failed to be written. Display names of increments
+// added since then are newer, so they are kept. Must be called with b.mu held.
+func (b *BufferedStorage) restore(stats NamedStats) {
+	key := statsKey{userID: stats.UserID, chatID: stats.ChatID}
+	if pending, ok := b.pending[key]; ok {
+		stats.UserDisplayName = pending.UserDisplayName
+		stats.SvoCount += pending.SvoCount
+		stats.ZovCount += pending.ZovCount
+		stats.LikvidirovanCount += pending.LikvidirovanCount
+	}
+	b.pending[key] = stats
+}
+
+// Flush writes pending increments to the underlying storage. If writing fails,
+// increments are kept and retried on the next flush. Flushes don't overlap, so once
+// Flush returns, all increments made before it was called are written.
+func (b *BufferedStorage) Flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	if len(b.pending) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	batch := slices.Collect(maps.Values(b.pending))
+	b.pending = make(map[statsKey]NamedStats)
+	b.mu.Unlock()
+
+	if err := b.Storage.IncreaseStatsBatch(ctx, batch); err != nil {
+		b.mu.Lock()
+		for _, stats := range batch {
+			b.restore(stats)
+		}
+		b.mu.Unlock()
+		return fmt.Errorf("increase stats batch: %w", err)
+	}
+
+	b.log.DebugContext(ctx, "flushed stats", "count", len(batch))
+	return nil
+}
+
+func (b *BufferedStorage) RetrieveStats(ctx context.Context, chatID int) ([]NamedStats, error) {
+	if err := b.Flush(ctx); err != nil {
+		return nil, fmt.Errorf("flush: %w", err)
+	}
+	return b.Storage.RetrieveStats(ctx, chatID)
+}
+
+// GetUserStats adds pending increments to the stored stats instead of flushing,
+// as it is called on the hot path of handling triggers. Reads don't wait for each other,
+// but do wait for a flush in progress, as its increments are neither pending nor stored
+// until it finishes.
+func (b *BufferedStorage) GetUserStats(ctx context.Context, userID, chatID int) (NamedStats, error) {
+	b.flushMu.RLock()
+	defer b.flushMu.RUnlock()
+
+	stats, err := b.Storage.GetUserStats(ctx, userID, chatID)
+	if err != nil {
+		return stats, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if pending, ok := b.pending[statsKey{userID: userID, chatID: chatID}]; ok {
+		stats.SvoCount += pending.SvoCount
+		stats.ZovCount += pending.ZovCount
+		stats.LikvidirovanCount += pending.LikvidirovanCount
+	}
+	return stats, nil
+}
+
+func (b *BufferedStorage) GetAllChats(ctx context.Context) ([]int, error) {
+	if err := b.Flush(ctx); err != nil {
+		return nil, fmt.Errorf("flush: %w", err)
+	}
+	return b.Storage.GetAllChats(ctx)
+}
+
+func (b *BufferedStorage) GetStats(ctx context.Context) (AggregatedStats, error) {
+	if err := b.Flush(ctx); err != nil {
+		return AggregatedStats{}, fmt.Errorf("flush: %w", err)
+	}
+	return b.Storage.GetStats(ctx)
+}
+
+func (b *BufferedStorage) ExportStats(ctx context.Context, chatID int) ([]NamedStats, error) {
+	if err := b.Flush(ctx); err != nil {
+		return nil, fmt.Errorf("flush: %w", err)
+	}
+	return b.Storage.ExportStats(ctx, chatID)
+}
+
+func (b *BufferedStorage) ImportStats(ctx context.Context, stats []NamedStats, strategy MergeStrategy) error {
+	if err := b.Flush(ctx); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	return b.Storage.ImportStats(ctx, stats, strategy)
+}
+
+// Close stops periodic flushing, writes remaining increments and closes the underlying storage.
+func (b *BufferedStorage) Close() error {
+	close(b.stop)
+	<-b.done
+
+	var errs []error
+	if err := b.Flush(context.Background()); err != nil {
+		errs = append(errs, fmt.Errorf("flush: %w", err))
+	}
+	if err := b.Storage.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close storage: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Unwrap returns the underlying storage.
+func (b *BufferedStorage) Unwrap() Storage {
+	return b.Storage
+}
@@ -0,0 +1,236 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+)
+
+// blockingStorage fails or blocks writes of stats batches.
+type blockingStorage struct {
+	db.Storage
+	fail    bool
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorage) IncreaseStatsBatch(ctx context.Context, batch []db.NamedStats) error {
+	if s.fail {
+		return errors.New("write failed")
+	}
+	if s.started != nil {
+		close(s.started)
+		<-s.release
+	}
+	return s.Storage.IncreaseStatsBatch(ctx, batch)
+}
+
+func TestBufferedStorageReadWaitsForFlush(t *testing.T) {
+	ctx := context.Background()
+	storage := &blockingStorage{
+		Storage: openSqlite(t),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	buffered := db.NewBufferedStorage(storage, time.Hour)
+	defer func() { _ = buffered.Close() }()
+
+	if err := buffered.IncreaseStats(ctx, db.NamedStats{UserID: 1, ChatID: 10, UserDisplayName: "alice", SvoCount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = buffered.Flush(ctx) }()
+	<-storage.started
+
+	retrieved := make(chan []db.NamedStats)
+	go func() {
+		stats, err := buffered.RetrieveStats(ctx, 10)
+		if err != nil {
+			t.Error(err)
+		}
+		retrieved <- stats
+	}()
+
+	select {
+	case stats := <-retrieved:
+		t.Fatalf("RetrieveStats() returned %+v before the flush in progress finished", stats)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	storage.started = nil
+	close(storage.release)
+	if stats := <-retrieved; len(stats) != 1 || stats[0].SvoCount != 1 {
+		t.Errorf("RetrieveStats() = %+v, want the flushed increment", stats)
+	}
+}
+
+func TestBufferedStorageFailedFlush(t *testing.T) {
+	ctx := context.Background()
+	storage := &blockingStorage{Storage: openSqlite(t), fail: true}
+	buffered := db.NewBufferedStorage(storage, time.Hour)
+	defer func() { _ = buffered.Close() }()
+
+	increase := func(name string) {
+		t.Helper()
+		if err := buffered.IncreaseStats(ctx, db.NamedStats{UserID: 1, ChatID: 10, UserDisplayName: name, ZovCount: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	increase("old")
+	if err := buffered.Flush(ctx); err == nil {
+		t.Fatal("Flush() succeeded with failing storage")
+	}
+	increase("new")
+
+	storage.fail = false
+	stats, err := buffered.RetrieveStats(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []db.NamedStats{{UserID: 1, ChatID: 10, UserDisplayName: "new", ZovCount: 2}}
+	if len(stats) != 1 || stats[0] != want[0] {
+		t.Errorf("RetrieveStats() = %+v, want %+v", stats, want)
+	}
+}
+
+// barrierStorage blocks reads of user stats until released, reporting each of them on arrival.
+type barrierStorage struct {
+	db.Storage
+	arrived chan struct{}
+	release chan struct{}
+}
+
+func (s *barrierStorage) GetUserStats(ctx context.Context, userID, chatID int) (db.NamedStats, error) {
+	s.arrived <- struct{}{}
+	<-s.release
+	return s.Storage.GetUserStats(ctx, userID, chatID)
+}
+
+func TestBufferedStorageConcurrentReads(t *testing.T) {
+	const readers = 4
+	ctx := context.Background()
+	storage := &barrierStorage{
+		Storage: openSqlite(t),
+		arrived: make(chan struct{}, readers),
+		release: make(chan struct{}),
+	}
+	buffered := db.NewBufferedStorage(storage, time.Hour)
+	defer func() { _ = buffered.Close() }()
+
+	errs := make(chan error, readers)
+	for range readers {
+		go func() {
+			_, err := buffered.GetUserStats(ctx, 1, 10)
+			errs <- err
+		}()
+	}
+	for i := range readers {
+		select {
+		case <-storage.arrived:
+		case <-time.After(time.Second):
+			close(storage.release)
+			t.Fatalf("only %d of %d reads run at once", i, readers)
+		}
+	}
+	close(storage.release)
+	for range readers {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBufferedStorageReadsDuringFlushes(t *testing.T) {
+	const increments = 200
+	ctx := context.Background()
+	buffered := db.NewBufferedStorage(openSqlite(t), time.Millisecond)
+	defer func() { _ = buffered.Close() }()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := 0
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				stats, err := buffered.GetUserStats(ctx, 1, 10)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// Increments are either pending or stored, so reads never go back or overshoot.
+				if stats.SvoCount < last || stats.SvoCount > increments {
+					t.Errorf("read %d svo after %d", stats.SvoCount, last)
+					return
+				}
+				last = stats.SvoCount
+			}
+		}()
+	}
+
+	for range increments {
+		if err := buffered.IncreaseStats(ctx, db.NamedStats{UserID: 1, ChatID: 10, UserDisplayName: "alice", SvoCount: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	stats, err := buffered.GetUserStats(ctx, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.SvoCount != increments {
+		t.Errorf("read %d svo, want %d", stats.SvoCount, increments)
+	}
+}
+
+func BenchmarkIncreaseStats(b *testing.B) {
+	ctx := context.Background()
+	stats := func(i int) db.NamedStats {
+		return db.NamedStats{UserID: i % 100, ChatID: i % 10, UserDisplayName: "user", SvoCount: 1}
+	}
+	open := func(b *testing.B) *db.DB {
+		storage, err := db.NewDB(b.TempDir() + "/svoi.db")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { _ = storage.Close() })
+		return storage
+	}
+
+	b.Run("direct", func(b *testing.B) {
+		storage := open(b)
+		b.ResetTimer()
+		for i := range b.N {
+			if err := storage.IncreaseStats(ctx, stats(i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		buffered := db.NewBufferedStorage(open(b), time.Hour)
+		b.Cleanup(func() { _ = buffered.Close() })
+		b.ResetTimer()
+		for i := range b.N {
+			if err := buffered.IncreaseStats(ctx, stats(i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		// Writing the aggregated increments is a part of the cost.
+		if err := buffered.Flush(ctx); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
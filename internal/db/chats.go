@@ -17,3 +17,14 @@ func (db *DB) GetAllChats(ctx context.Context) (chatIDs []int, _ error) {
 	}
 	return chatIDs, nil
 }
+
+func (db *DB) GetStats(ctx context.Context) (AggregatedStats, error) {
+	stats, err := db.Queries.GetStats(ctx)
+	if err != nil {
+		return AggregatedStats{}, fmt.Errorf("get stats: %w", err)
+	}
+	return AggregatedStats{
+		TotalUsers: int(stats.TotalUsers),
+		TotalChats: int(stats.TotalChats),
+	}, nil
+}
@@ -25,7 +25,7 @@ func NewDB(dbPath string) (*DB, error) {
 	if dbPath == InMemory {
 		connectionString = dbPath
 	} else {
-		connectionString = fmt.Sprintf("file://%s?mode=rwc&cache=shared&_journal_mode=WAL", dbPath)
+		connectionString = fileURI(dbPath, "mode=rwc&cache=shared&_journal_mode=WAL")
 	}
 
 	db, err := sql.Open("sqlite", connectionString)
@@ -44,3 +44,10 @@ func NewDB(dbPath string) (*DB, error) {
 		Queries: queries,
 	}, nil
 }
+
+// fileURI makes a sqlite URI for the database file at path. Paths are used as is rather
+// than as the path of a file:// URL, in which the first element of relative paths would
+// be taken for the host.
+func fileURI(path, params string) string {
+	return "file:" + path + "?" + params
+}
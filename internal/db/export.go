@@ -0,0 +1,252 @@
+package db
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db/q"
+)
+
+type ExportFormat string
+
+const (
+	FormatCSV  ExportFormat = "csv"
+	FormatJSON ExportFormat = "json"
+)
+
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(s); format {
+	case FormatCSV, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, expected %q or %q", s, FormatCSV, FormatJSON)
+	}
+}
+
+// MergeStrategy defines how imported counters are combined with the ones already stored.
+type MergeStrategy string
+
+const (
+	MergeSum     MergeStrategy = "sum"
+	MergeReplace MergeStrategy = "replace"
+	MergeMax     MergeStrategy = "max"
+)
+
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch strategy := MergeStrategy(s); strategy {
+	case MergeSum, MergeReplace, MergeMax:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy %q, expected %q, %q or %q", s, MergeSum, MergeReplace, MergeMax)
+	}
+}
+
+// ExportStats returns stats of all users, optionally limited to a single chat.
+// Chat is not filtered if chatID is 0.
+func (db *DB) ExportStats(ctx context.Context, chatID int) ([]NamedStats, error) {
+	var rows []q.ExportStatsRow
+	if chatID == 0 {
+		var err error
+		rows, err = db.Queries.ExportStats(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("export stats: %w", err)
+		}
+	} else {
+		chatRows, err := db.Queries.ExportChatStats(ctx, int64(chatID))
+		if err != nil {
+			return nil, fmt.Errorf("export chat stats: %w", err)
+		}
+		for _, row := range chatRows {
+			rows = append(rows, q.ExportStatsRow(row))
+		}
+	}
+
+	namedStats := make([]NamedStats, 0, len(rows))
+	for _, stat := range rows {
+		namedStats = append(namedStats, NamedStats{
+			UserID:            int(stat.UserID),
+			ChatID:            int(stat.ChatID),
+			UserDisplayName:   stat.DisplayedName,
+			SvoCount:          int(stat.SvoCount),
+			ZovCount:          int(stat.ZovCount),
+			LikvidirovanCount: int(stat.LikvidirovanCount),
+		})
+	}
+	return namedStats, nil
+}
+
+// ImportStats merges stats into the database in a single transaction. Display names
+// of existing users are replaced with imported ones regardless of the strategy.
+func (db *DB) ImportStats(ctx context.Context, stats []NamedStats, strategy MergeStrategy) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	queries := db.WithTx(tx)
+	for _, stat := range stats {
+		err := queries.UpsertUser(ctx, q.UpsertUserParams{
+			ID:            int64(stat.UserID),
+			DisplayedName: stat.UserDisplayName,
+		})
+		if err != nil {
+			return fmt.Errorf("upsert user %d: %w", stat.UserID, err)
+		}
+
+		if err := mergeStat(ctx, queries, stat, strategy); err != nil {
+			return fmt.Errorf("merge stats of user %d in chat %d: %w", stat.UserID, stat.ChatID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit changes: %w", err)
+	}
+	return nil
+}
+
+func mergeStat(ctx context.Context, queries *q.Queries, stat NamedStats, strategy MergeStrategy) error {
+	switch strategy {
+	case MergeSum:
+		return queries.AddStats(ctx, q.AddStatsParams{
+			UserID:            int64(stat.UserID),
+			ChatID:            int64(stat.ChatID),
+			SvoCount:          int64(stat.SvoCount),
+			ZovCount:          int64(stat.ZovCount),
+			LikvidirovanCount: int64(stat.LikvidirovanCount),
+		})
+
+	case MergeReplace:
+		return queries.ReplaceStats(ctx, q.ReplaceStatsParams{
+			UserID:            int64(stat.UserID),
+			ChatID:            int64(stat.ChatID),
+			SvoCount:          int64(stat.SvoCount),
+			ZovCount:          int64(stat.ZovCount),
+			LikvidirovanCount: int64(stat.LikvidirovanCount),
+		})
+
+	case MergeMax:
+		return queries.MaxStats(ctx, q.MaxStatsParams{
+			UserID:            int64(stat.UserID),
+			ChatID:            int64(stat.ChatID),
+			SvoCount:          int64(stat.SvoCount),
+			ZovCount:          int64(stat.ZovCount),
+			LikvidirovanCount: int64(stat.LikvidirovanCount),
+		})
+
+	default:
+		return fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+}
+
+var csvHeader = []string{"user_id", "chat_id", "user_display_name", "svo_count", "zov_count", "likvidirovan_count"}
+
+func WriteStats(w io.Writer, format ExportFormat, stats []NamedStats) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stats); err != nil {
+			return fmt.Errorf("encode json: %w", err)
+		}
+		return nil
+
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return fmt.Errorf("write csv header: %w", err)
+		}
+		for _, stat := range stats {
+			err := writer.Write([]string{
+				strconv.Itoa(stat.UserID),
+				strconv.Itoa(stat.ChatID),
+				stat.UserDisplayName,
+				strconv.Itoa(stat.SvoCount),
+				strconv.Itoa(stat.ZovCount),
+				strconv.Itoa(stat.LikvidirovanCount),
+			})
+			if err != nil {
+				return fmt.Errorf("write csv record: %w", err)
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("flush csv: %w", err)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func ReadStats(r io.Reader, format ExportFormat) ([]NamedStats, error) {
+	switch format {
+	case FormatJSON:
+		var stats []NamedStats
+		if err := json.NewDecoder(r).Decode(&stats); err != nil {
+			return nil, fmt.Errorf("decode json: %w", err)
+		}
+		return stats, nil
+
+	case FormatCSV:
+		return readCSVStats(r)
+
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func readCSVStats(r io.Reader) ([]NamedStats, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	for i, column := range csvHeader {
+		if header[i] != column {
+			return nil, fmt.Errorf("unexpected csv column %d: expected %q, got %q", i+1, column, header[i])
+		}
+	}
+
+	var stats []NamedStats
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv record: %w", err)
+		}
+
+		var stat NamedStats
+		var errs []error
+		parseInt := func(s string) int {
+			v, err := strconv.Atoi(s)
+			errs = append(errs, err)
+			return v
+		}
+		stat.UserID = parseInt(record[0])
+		stat.ChatID = parseInt(record[1])
+		stat.UserDisplayName = record[2]
+		stat.SvoCount = parseInt(record[3])
+		stat.ZovCount = parseInt(record[4])
+		stat.LikvidirovanCount = parseInt(record[5])
+		if err := errors.Join(errs...); err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("parse csv record on line %d: %w", line, err)
+		}
+
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
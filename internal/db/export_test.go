@@ -0,0 +1,142 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+)
+
+var exportedStats = []db.NamedStats{
+	{UserID: 1, ChatID: -100, UserDisplayName: "Иван Иванов", SvoCount: 3, ZovCount: 1, LikvidirovanCount: 2},
+	{UserID: 2, ChatID: -100, UserDisplayName: `name, with "quotes"` + "\nand newline", SvoCount: 10},
+	{UserID: 2, ChatID: 5, UserDisplayName: "", ZovCount: 7},
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want db.ExportFormat
+		ok   bool
+	}{
+		{in: "csv", want: db.FormatCSV, ok: true},
+		{in: "json", want: db.FormatJSON, ok: true},
+		{in: "CSV"},
+		{in: "xml"},
+		{in: ""},
+	} {
+		got, err := db.ParseExportFormat(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseExportFormat(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestStatsRoundTrip(t *testing.T) {
+	for _, format := range []db.ExportFormat{db.FormatCSV, db.FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := db.WriteStats(&buf, format, exportedStats); err != nil {
+				t.Fatalf("write stats: %s", err)
+			}
+			got, err := db.ReadStats(&buf, format)
+			if err != nil {
+				t.Fatalf("read stats: %s", err)
+			}
+			if !reflect.DeepEqual(got, exportedStats) {
+				t.Errorf("got %+v, want %+v", got, exportedStats)
+			}
+		})
+	}
+}
+
+func TestReadStatsErrors(t *testing.T) {
+	const header = "user_id,chat_id,user_display_name,svo_count,zov_count,likvidirovan_count\n"
+	for _, tc := range []struct {
+		name   string
+		format db.ExportFormat
+		in     string
+	}{
+		{name: "empty csv", format: db.FormatCSV, in: ""},
+		{name: "wrong column", format: db.FormatCSV, in: "user_id,chat_id,name,svo_count,zov_count,likvidirovan_count\n"},
+		{name: "missing column", format: db.FormatCSV, in: "user_id,chat_id,user_display_name,svo_count,zov_count\n"},
+		{name: "short row", format: db.FormatCSV, in: header + "1,2,name,3,4\n"},
+		{name: "long row", format: db.FormatCSV, in: header + "1,2,name,3,4,5,6\n"},
+		{name: "not a number", format: db.FormatCSV, in: header + "1,2,name,three,4,5\n"},
+		{name: "malformed quotes", format: db.FormatCSV, in: header + "1,2,\"name,3,4,5\n"},
+		{name: "malformed json", format: db.FormatJSON, in: `[{"user_id": 1`},
+		{name: "wrong json type", format: db.FormatJSON, in: `[{"user_id": "one"}]`},
+		{name: "unknown format", format: "xml", in: "<stats/>"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if stats, err := db.ReadStats(strings.NewReader(tc.in), tc.format); err == nil {
+				t.Errorf("read %+v from invalid input", stats)
+			}
+		})
+	}
+}
+
+func TestReadStatsReportsLine(t *testing.T) {
+	in := "user_id,chat_id,user_display_name,svo_count,zov_count,likvidirovan_count\n" +
+		"1,2,name,3,4,5\n" +
+		"1,3,name,x,4,5\n"
+	_, err := db.ReadStats(strings.NewReader(in), db.FormatCSV)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("error %v does not point to line 3", err)
+	}
+}
+
+func TestExportImportStats(t *testing.T) {
+	ctx := context.Background()
+	source := openSqlite(t)
+	for _, stat := range exportedStats {
+		if err := source.IncreaseStats(ctx, stat); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exported, err := source.ExportStats(ctx, -100)
+	if err != nil {
+		t.Fatalf("export stats: %s", err)
+	}
+	if len(exported) != 2 {
+		t.Fatalf("exported %d stats of the chat, want 2", len(exported))
+	}
+
+	for _, tc := range []struct {
+		strategy db.MergeStrategy
+		want     int
+	}{
+		{strategy: db.MergeSum, want: 13},
+		{strategy: db.MergeReplace, want: 10},
+		{strategy: db.MergeMax, want: 20},
+	} {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			target := openSqlite(t)
+			existing := db.NamedStats{UserID: 2, ChatID: -100, UserDisplayName: "old name", SvoCount: 3}
+			if tc.strategy == db.MergeMax {
+				existing.SvoCount = 20
+			}
+			if err := target.IncreaseStats(ctx, existing); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := target.ImportStats(ctx, exported, tc.strategy); err != nil {
+				t.Fatalf("import stats: %s", err)
+			}
+			got, err := target.GetUserStats(ctx, 2, -100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.SvoCount != tc.want {
+				t.Errorf("svo count is %d, want %d", got.SvoCount, tc.want)
+			}
+			if got.UserDisplayName == existing.UserDisplayName {
+				t.Errorf("display name %q was not replaced with the imported one", got.UserDisplayName)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	backupFilePrefix = "svoi-"
+	backupFileSuffix = ".db"
+	// backupFileTimeFormat names backups with microseconds, so that backups made within
+	// the same second don't collide. Backups named without them are still recognized,
+	// as fractional seconds are accepted when parsing.
+	backupFileTimeFormat  = "20060102-150405.000000"
+	backupFileParseFormat = "20060102-150405"
+)
+
+func (db *DB) Vacuum(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the database to path while it stays available
+// for reads and writes. The file at path must not exist.
+func (db *DB) Backup(ctx context.Context, path string) error {
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("vacuum into %q: %w", path, err)
+	}
+	return nil
+}
+
+// BackupToDir writes a timestamped backup into dir and verifies its integrity.
+// Backups that fail the integrity check are removed.
+func (db *DB) BackupToDir(ctx context.Context, dir string, now time.Time) (path string, _ error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+
+	path = filepath.Join(dir, backupFilePrefix+now.UTC().Format(backupFileTimeFormat)+backupFileSuffix)
+	if err := db.Backup(ctx, path); err != nil {
+		return "", err
+	}
+
+	if err := CheckIntegrity(ctx, path); err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("check backup integrity: %w", err)
+	}
+	return path, nil
+}
+
+// CheckIntegrity runs sqlite integrity check against the database file at path.
+func CheckIntegrity(ctx context.Context, path string) error {
+	conn, err := sql.Open("sqlite", fileURI(path, "mode=ro"))
+	if err != nil {
+		return fmt.Errorf("open db file: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	rows, err := conn.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("run integrity check: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("scan integrity check result: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read integrity check results: %w", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// PruneBackups removes backups in dir, keeping at most keepLast newest ones
+// and none older than maxAge. Zero values disable the corresponding limit.
+func PruneBackups(dir string, keepLast int, maxAge time.Duration, now time.Time) (removed []string, _ error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read backup dir: %w", err)
+	}
+
+	type backup struct {
+		name      string
+		createdAt time.Time
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupFilePrefix) || !strings.HasSuffix(name, backupFileSuffix) {
+			continue
+		}
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(name, backupFilePrefix), backupFileSuffix)
+		createdAt, err := time.Parse(backupFileParseFormat, timestamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name: name, createdAt: createdAt})
+	}
+
+	slices.SortFunc(backups, func(a, b backup) int {
+		return b.createdAt.Compare(a.createdAt)
+	})
+
+	var errs []error
+	for i, b := range backups {
+		expired := maxAge > 0 && now.Sub(b.createdAt) > maxAge
+		extra := keepLast > 0 && i >= keepLast
+		if !expired && !extra {
+			continue
+		}
+
+		path := filepath.Join(dir, b.name)
+		if err := os.Remove(path); err != nil {
+			errs = append(errs, fmt.Errorf("remove %q: %w", path, err))
+			continue
+		}
+		removed = append(removed, path)
+	}
+	return removed, errors.Join(errs...)
+}
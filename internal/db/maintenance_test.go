@@ -0,0 +1,121 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+)
+
+func TestBackupToDir(t *testing.T) {
+	ctx := context.Background()
+	storage := openSqlite(t)
+	stat := db.NamedStats{UserID: 1, ChatID: 2, UserDisplayName: "Иван", SvoCount: 3}
+	if err := storage.IncreaseStats(ctx, stat); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backup dir is usually relative to the working directory of the bot.
+	t.Chdir(t.TempDir())
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	path, err := storage.BackupToDir(ctx, "backups", now)
+	if err != nil {
+		t.Fatalf("backup to relative dir: %s", err)
+	}
+	if filepath.Dir(path) != "backups" {
+		t.Errorf("backup %q is not in the backup dir", path)
+	}
+
+	// Backups made within the same second must not collide.
+	second, err := storage.BackupToDir(ctx, "backups", now.Add(time.Millisecond))
+	if err != nil {
+		t.Fatalf("second backup within a second: %s", err)
+	}
+	if second == path {
+		t.Errorf("both backups were written to %q", path)
+	}
+
+	for _, path := range []string{path, second} {
+		if err := db.CheckIntegrity(ctx, path); err != nil {
+			t.Errorf("check integrity of %q: %s", path, err)
+		}
+		backup, err := db.NewDB(path)
+		if err != nil {
+			t.Fatalf("open backup: %s", err)
+		}
+		got, err := backup.GetUserStats(ctx, stat.UserID, stat.ChatID)
+		_ = backup.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.SvoCount != stat.SvoCount {
+			t.Errorf("backup %q has svo count %d, want %d", path, got.SvoCount, stat.SvoCount)
+		}
+	}
+}
+
+func TestCheckIntegrityCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrupted.db")
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckIntegrity(context.Background(), path); err == nil {
+		t.Error("corrupted database passed the integrity check")
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	backups := []string{
+		"svoi-20261019-115959.123456.db",
+		"svoi-20261019-115959.000001.db",
+		"svoi-20261018-120000.000000.db",
+		// Named before backups had sub-second precision.
+		"svoi-20261017-120000.db",
+		"svoi-20261001-120000.db",
+	}
+	unrelated := []string{"svoi.db", "svoi-latest.db", "notes.txt", "svoi-20261001-120000.db-wal"}
+
+	for _, tc := range []struct {
+		name     string
+		keepLast int
+		maxAge   time.Duration
+		removed  []string
+	}{
+		{name: "no limits"},
+		{name: "keep last", keepLast: 2, removed: backups[2:]},
+		{name: "max age", maxAge: 48 * time.Hour, removed: backups[4:]},
+		{name: "both", keepLast: 4, maxAge: 36 * time.Hour, removed: backups[3:]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range append(slices.Clone(backups), unrelated...) {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			removed, err := db.PruneBackups(dir, tc.keepLast, tc.maxAge, now)
+			if err != nil {
+				t.Fatalf("prune backups: %s", err)
+			}
+			var want []string
+			for _, name := range tc.removed {
+				want = append(want, filepath.Join(dir, name))
+			}
+			if !slices.Equal(removed, want) {
+				t.Errorf("removed %v, want %v", removed, want)
+			}
+
+			for _, name := range unrelated {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("unrelated file %q was removed", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db/q"
+)
+
+func (db *DB) GetMediaFileID(ctx context.Context, hash string) (string, bool, error) {
+	fileID, err := db.Queries.GetMediaFileID(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("get media file id: %w", err)
+	}
+	return fileID, true, nil
+}
+
+func (db *DB) SetMediaFileID(ctx context.Context, hash, fileID string) error {
+	err := db.Queries.SetMediaFileID(ctx, q.SetMediaFileIDParams{
+		Hash:   hash,
+		FileID: fileID,
+	})
+	if err != nil {
+		return fmt.Errorf("set media file id: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+
+	_ "github.com/lib/pq"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/db/postgres/q"
+)
+
+//go:embed schema.sql
+var ddl string
+
+type DB struct {
+	*sql.DB
+	*q.Queries
+}
+
+var _ db.Storage = (*DB)(nil)
+
+func NewDB(ctx context.Context, dsn string) (*DB, error) {
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open db connection: %w", err)
+	}
+
+	_, err = conn.ExecContext(ctx, ddl)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("init db: %w", err)
+	}
+
+	return &DB{
+		DB:      conn,
+		Queries: q.New(conn),
+	}, nil
+}
+
+func (pg *DB) IncreaseStats(ctx context.Context, stats db.NamedStats) error {
+	return pg.IncreaseStatsBatch(ctx, []db.NamedStats{stats})
+}
+
+// IncreaseStatsBatch adds all increments in a single transaction.
+func (pg *DB) IncreaseStatsBatch(ctx context.Context, batch []db.NamedStats) error {
+	tx, err := pg.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	queries := pg.WithTx(tx)
+	for _, stats := range batch {
+		if stats.UserID == db.TelegramUserID {
+			continue
+		}
+
+		err := queries.UpsertUser(ctx, q.UpsertUserParams{
+			ID:            int64(stats.UserID),
+			DisplayedName: stats.UserDisplayName,
+		})
+		if err != nil {
+			return fmt.Errorf("upsert user %d: %w", stats.UserID, err)
+		}
+
+		err = queries.AddStats(ctx, q.AddStatsParams{
+			UserID:            int64(stats.UserID),
+			ChatID:            int64(stats.ChatID),
+			SvoCount:          int64(stats.SvoCount),
+			ZovCount:          int64(stats.ZovCount),
+			LikvidirovanCount: int64(stats.LikvidirovanCount),
+		})
+		if err != nil {
+			return fmt.Errorf("add stats: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit changes: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *DB) RetrieveStats(ctx context.Context, chatID int) ([]db.NamedStats, error) {
+	stats, err := pg.GetChatStats(ctx, int64(chatID))
+	if err != nil {
+		return nil, fmt.Errorf("get chat stats: %w", err)
+	}
+
+	namedStats := make([]db.NamedStats, 0, len(stats))
+	for _, stat := range stats {
+		namedStats = append(namedStats, db.NamedStats{
+			UserID:            int(stat.UserID),
+			ChatID:            chatID,
+			UserDisplayName:   stat.DisplayedName,
+			SvoCount:          int(stat.SvoCount),
+			ZovCount:          int(stat.ZovCount),
+			LikvidirovanCount: int(stat.LikvidirovanCount),
+		})
+	}
+	return namedStats, nil
+}
+
+func (pg *DB) GetUserStats(ctx context.Context, userID, chatID int) (db.NamedStats, error) {
+	stats := db.NamedStats{UserID: userID, ChatID: chatID}
+	row, err := pg.Queries.GetUserStats(ctx, q.GetUserStatsParams{
+		UserID: int64(userID),
+		ChatID: int64(chatID),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("get user stats: %w", err)
+	}
+
+	stats.SvoCount = int(row.SvoCount)
+	stats.ZovCount = int(row.ZovCount)
+	stats.LikvidirovanCount = int(row.LikvidirovanCount)
+	return stats, nil
+}
+
+func (pg *DB) GetAllChats(ctx context.Context) (chatIDs []int, _ error) {
+	chats, err := pg.Queries.GetAllChats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get all chats: %w", err)
+	}
+
+	chatIDs = make([]int, 0, len(chats))
+	for _, chatID := range chats {
+		chatIDs = append(chatIDs, int(chatID))
+	}
+	return chatIDs, nil
+}
+
+func (pg *DB) GetStats(ctx context.Context) (db.AggregatedStats, error) {
+	stats, err := pg.Queries.GetStats(ctx)
+	if err != nil {
+		return db.AggregatedStats{}, fmt.Errorf("get stats: %w", err)
+	}
+	return db.AggregatedStats{
+		TotalUsers: int(stats.TotalUsers),
+		TotalChats: int(stats.TotalChats),
+	}, nil
+}
+
+func (pg *DB) ExportStats(ctx context.Context, chatID int) ([]db.NamedStats, error) {
+	var rows []q.ExportStatsRow
+	if chatID == 0 {
+		var err error
+		rows, err = pg.Queries.ExportStats(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("export stats: %w", err)
+		}
+	} else {
+		chatRows, err := pg.Queries.ExportChatStats(ctx, int64(chatID))
+		if err != nil {
+			return nil, fmt.Errorf("export chat stats: %w", err)
+		}
+		for _, row := range chatRows {
+			rows = append(rows, q.ExportStatsRow(row))
+		}
+	}
+
+	namedStats := make([]db.NamedStats, 0, len(rows))
+	for _, stat := range rows {
+		namedStats = append(namedStats, db.NamedStats{
+			UserID:            int(stat.UserID),
+			ChatID:            int(stat.ChatID),
+			UserDisplayName:   stat.DisplayedName,
+			SvoCount:          int(stat.SvoCount),
+			ZovCount:          int(stat.ZovCount),
+			LikvidirovanCount: int(stat.LikvidirovanCount),
+		})
+	}
+	return namedStats, nil
+}
+
+func (pg *DB) ImportStats(ctx context.Context, stats []db.NamedStats, strategy db.MergeStrategy) error {
+	tx, err := pg.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	queries := pg.WithTx(tx)
+	for _, stat := range stats {
+		err := queries.UpsertUser(ctx, q.UpsertUserParams{
+			ID:            int64(stat.UserID),
+			DisplayedName: stat.UserDisplayName,
+		})
+		if err != nil {
+			return fmt.Errorf("upsert user %d: %w", stat.UserID, err)
+		}
+
+		if err := mergeStat(ctx, queries, stat, strategy); err != nil {
+			return fmt.Errorf("merge stats of user %d in chat %d: %w", stat.UserID, stat.ChatID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit changes: %w", err)
+	}
+	return nil
+}
+
+func mergeStat(ctx context.Context, queries *q.Queries, stat db.NamedStats, strategy db.MergeStrategy) error {
+	switch strategy {
+	case db.MergeSum:
+		return queries.AddStats(ctx, q.AddStatsParams{
+			UserID:            int64(stat.UserID),
+			ChatID:            int64(stat.ChatID),
+			SvoCount:          int64(stat.SvoCount),
+			ZovCount:          int64(stat.ZovCount),
+			LikvidirovanCount: int64(stat.LikvidirovanCount),
+		})
+
+	case db.MergeReplace:
+		return queries.ReplaceStats(ctx, q.ReplaceStatsParams{
+			UserID:            int64(stat.UserID),
+			ChatID:            int64(stat.ChatID),
+			SvoCount:          int64(stat.SvoCount),
+			ZovCount:          int64(stat.ZovCount),
+			LikvidirovanCount: int64(stat.LikvidirovanCount),
+		})
+
+	case db.MergeMax:
+		return queries.MaxStats(ctx, q.MaxStatsParams{
+			UserID:            int64(stat.UserID),
+			ChatID:            int64(stat.ChatID),
+			SvoCount:          int64(stat.SvoCount),
+			ZovCount:          int64(stat.ZovCount),
+			LikvidirovanCount: int64(stat.LikvidirovanCount),
+		})
+
+	default:
+		return fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+}
+
+func (pg *DB) GetChatSetting(ctx context.Context, chatID int, key string) (string, bool, error) {
+	value, err := pg.Queries.GetChatSetting(ctx, q.GetChatSettingParams{
+		ChatID: int64(chatID),
+		Key:    key,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("get chat setting: %w", err)
+	}
+	return value, true, nil
+}
+
+func (pg *DB) SetChatSetting(ctx context.Context, chatID int, key, value string) error {
+	err := pg.Queries.SetChatSetting(ctx, q.SetChatSettingParams{
+		ChatID: int64(chatID),
+		Key:    key,
+		Value:  value,
+	})
+	if err != nil {
+		return fmt.Errorf("set chat setting: %w", err)
+	}
+	return nil
+}
+
+func (pg *DB) DeleteChatSetting(ctx context.Context, chatID int, key string) error {
+	err := pg.Queries.DeleteChatSetting(ctx, q.DeleteChatSettingParams{
+		ChatID: int64(chatID),
+		Key:    key,
+	})
+	if err != nil {
+		return fmt.Errorf("delete chat setting: %w", err)
+	}
+	return nil
+}
+
+func (pg *DB) GetMediaFileID(ctx context.Context, hash string) (string, bool, error) {
+	fileID, err := pg.Queries.GetMediaFileID(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("get media file id: %w", err)
+	}
+	return fileID, true, nil
+}
+
+func (pg *DB) SetMediaFileID(ctx context.Context, hash, fileID string) error {
+	err := pg.Queries.SetMediaFileID(ctx, q.SetMediaFileIDParams{
+		Hash:   hash,
+		FileID: fileID,
+	})
+	if err != nil {
+		return fmt.Errorf("set media file id: %w", err)
+	}
+	return nil
+}
+
+func (pg *DB) IncreaseStickerUsage(ctx context.Context, setName, stickerID string) error {
+	err := pg.Queries.AddStickerUsage(ctx, q.AddStickerUsageParams{
+		StickerID: stickerID,
+		SetName:   setName,
+	})
+	if err != nil {
+		return fmt.Errorf("add sticker usage: %w", err)
+	}
+	return nil
+}
+
+func (pg *DB) GetStickerSetUsage(ctx context.Context) (map[string]int, error) {
+	rows, err := pg.Queries.GetStickerSetUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get sticker set usage: %w", err)
+	}
+
+	usage := make(map[string]int, len(rows))
+	for _, row := range rows {
+		usage[row.SetName] = int(row.UsageCount)
+	}
+	return usage, nil
+}
+
+func (pg *DB) GetStickerSets(ctx context.Context) ([]db.StickerSet, error) {
+	sets, err := pg.Queries.GetStickerSets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get sticker sets: %w", err)
+	}
+
+	result := make([]db.StickerSet, 0, len(sets))
+	for _, set := range sets {
+		result = append(result, db.StickerSet{
+			Name:    set.Name,
+			Enabled: set.Enabled,
+		})
+	}
+	return result, nil
+}
+
+func (pg *DB) SetStickerSetEnabled(ctx context.Context, name string, enabled bool) error {
+	err := pg.Queries.UpsertStickerSet(ctx, q.UpsertStickerSetParams{
+		Name:    name,
+		Enabled: enabled,
+	})
+	if err != nil {
+		return fmt.Errorf("upsert sticker set: %w", err)
+	}
+	return nil
+}
+
+// DeleteStickerSet deletes the set along with its excluded stickers in a single transaction.
+func (pg *DB) DeleteStickerSet(ctx context.Context, name string) error {
+	tx, err := pg.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	queries := pg.WithTx(tx)
+	if err := queries.DeleteStickerExclusions(ctx, name); err != nil {
+		return fmt.Errorf("delete sticker exclusions: %w", err)
+	}
+	if err := queries.DeleteStickerSet(ctx, name); err != nil {
+		return fmt.Errorf("delete sticker set: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit changes: %w", err)
+	}
+	return nil
+}
+
+func (pg *DB) GetExcludedStickers(ctx context.Context) (map[string][]string, error) {
+	exclusions, err := pg.Queries.GetStickerExclusions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get sticker exclusions: %w", err)
+	}
+
+	excluded := make(map[string][]string)
+	for _, exclusion := range exclusions {
+		excluded[exclusion.SetName] = append(excluded[exclusion.SetName], exclusion.StickerID)
+	}
+	return excluded, nil
+}
+
+func (pg *DB) ExcludeSticker(ctx context.Context, setName, stickerID string) error {
+	err := pg.Queries.AddStickerExclusion(ctx, q.AddStickerExclusionParams{
+		SetName:   setName,
+		StickerID: stickerID,
+	})
+	if err != nil {
+		return fmt.Errorf("add sticker exclusion: %w", err)
+	}
+	return nil
+}
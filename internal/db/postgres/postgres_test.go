@@ -0,0 +1,147 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/db/postgres"
+	"github.com/LeKSuS-04/svoi-bot/internal/db/storagetest"
+)
+
+// testDSNEnv is the environment variable with URL of a Postgres database for tests.
+// If it is not set, a throwaway server is started with docker.
+const testDSNEnv = "SVOIBOT_TEST_POSTGRES_DSN"
+
+func TestPostgresStorage(t *testing.T) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		dsn = startContainer(t)
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if err := waitReady(conn); err != nil {
+		t.Fatalf("postgres is not ready: %v", err)
+	}
+
+	// Every test gets its own schema, so that tests start with an empty database.
+	n := 0
+	open := func(t *testing.T) *postgres.DB {
+		n++
+		schema := fmt.Sprintf("storagetest_%d_%d", time.Now().UnixNano(), n)
+		if _, err := conn.Exec("CREATE SCHEMA " + schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _, _ = conn.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+		storage, err := postgres.NewDB(context.Background(), withSearchPath(t, dsn, schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = storage.Close() })
+		return storage
+	}
+
+	storagetest.Run(t, func(t *testing.T) db.Storage {
+		return open(t)
+	})
+	t.Run("DeleteStickerSetAtomic", func(t *testing.T) {
+		testDeleteStickerSetAtomic(t, open(t))
+	})
+}
+
+func testDeleteStickerSetAtomic(t *testing.T, storage *postgres.DB) {
+	ctx := context.Background()
+	if err := storage.SetStickerSetEnabled(ctx, "set", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.ExcludeSticker(ctx, "set", "sticker"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the second statement, after exclusions are deleted.
+	for _, query := range []string{
+		`CREATE FUNCTION fail_delete() RETURNS trigger AS $$ BEGIN RAISE EXCEPTION 'delete failed'; END $$ LANGUAGE plpgsql`,
+		`CREATE TRIGGER fail_delete BEFORE DELETE ON sticker_sets FOR EACH ROW EXECUTE FUNCTION fail_delete()`,
+	} {
+		if _, err := storage.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.DeleteStickerSet(ctx, "set"); err == nil {
+		t.Fatal("DeleteStickerSet() succeeded despite failing delete")
+	}
+
+	excluded, err := storage.GetExcludedStickers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(excluded["set"], []string{"sticker"}) {
+		t.Errorf("excluded stickers are %v after failed delete, want them kept", excluded["set"])
+	}
+}
+
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("%s must be a postgres:// url: %v", testDSNEnv, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// startContainer starts Postgres in docker and returns its DSN. The test is skipped
+// if docker is not available.
+func startContainer(t *testing.T) string {
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("neither %s is set nor docker is available", testDSNEnv)
+	}
+
+	out, err := exec.Command("docker", "run", "--detach", "--rm",
+		"--env", "POSTGRES_PASSWORD=test",
+		"--publish", "127.0.0.1::5432",
+		"postgres:16-alpine",
+	).Output()
+	if err != nil {
+		t.Skipf("failed to start postgres container: %v", err)
+	}
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() { _ = exec.Command("docker", "stop", id).Run() })
+
+	out, err = exec.Command("docker", "port", id, "5432/tcp").Output()
+	if err != nil {
+		t.Fatalf("get postgres container port: %v", err)
+	}
+	address := strings.TrimSpace(strings.Split(string(out), "\n")[0])
+	return fmt.Sprintf("postgres://postgres:test@%s/postgres?sslmode=disable", address)
+}
+
+func waitReady(conn *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for {
+		err := conn.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,140 @@
+-- name: UpsertUser :exec
+INSERT INTO users (id, displayed_name)
+VALUES ($1, $2)
+ON CONFLICT (id) DO UPDATE SET displayed_name = EXCLUDED.displayed_name
+WHERE users.displayed_name <> EXCLUDED.displayed_name;
+
+-- name: AddStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = stats.svo_count + EXCLUDED.svo_count,
+    zov_count = stats.zov_count + EXCLUDED.zov_count,
+    likvidirovan_count = stats.likvidirovan_count + EXCLUDED.likvidirovan_count;
+
+-- name: ReplaceStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = EXCLUDED.svo_count,
+    zov_count = EXCLUDED.zov_count,
+    likvidirovan_count = EXCLUDED.likvidirovan_count;
+
+-- name: MaxStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = GREATEST(stats.svo_count, EXCLUDED.svo_count),
+    zov_count = GREATEST(stats.zov_count, EXCLUDED.zov_count),
+    likvidirovan_count = GREATEST(stats.likvidirovan_count, EXCLUDED.likvidirovan_count);
+
+-- name: GetChatStats :many
+SELECT stats.user_id, users.displayed_name, stats.svo_count, stats.zov_count, stats.likvidirovan_count
+FROM stats
+JOIN users ON users.id = stats.user_id
+WHERE stats.chat_id = $1 AND stats.svo_count + stats.zov_count > 0
+ORDER BY stats.svo_count + stats.zov_count DESC;
+
+-- name: GetAllChats :many
+SELECT DISTINCT chat_id
+FROM stats;
+
+-- name: GetStats :one
+SELECT
+    COUNT(DISTINCT user_id) as total_users,
+    COUNT(DISTINCT chat_id) as total_chats
+FROM stats;
+
+-- name: ExportStats :many
+SELECT
+    stats.user_id,
+    stats.chat_id,
+    users.displayed_name,
+    stats.svo_count,
+    stats.zov_count,
+    stats.likvidirovan_count
+FROM stats
+JOIN users ON users.id = stats.user_id
+ORDER BY stats.chat_id, stats.user_id;
+
+-- name: ExportChatStats :many
+SELECT
+    stats.user_id,
+    stats.chat_id,
+    users.displayed_name,
+    stats.svo_count,
+    stats.zov_count,
+    stats.likvidirovan_count
+FROM stats
+JOIN users ON users.id = stats.user_id
+WHERE stats.chat_id = $1
+ORDER BY stats.user_id;
+
+-- name: GetUserStats :one
+SELECT svo_count, zov_count, likvidirovan_count
+FROM stats
+WHERE user_id = $1 AND chat_id = $2;
+
+-- name: GetChatSetting :one
+SELECT value
+FROM chat_settings
+WHERE chat_id = $1 AND key = $2;
+
+-- name: SetChatSetting :exec
+INSERT INTO chat_settings (chat_id, key, value)
+VALUES ($1, $2, $3)
+ON CONFLICT (chat_id, key) DO UPDATE SET value = EXCLUDED.value;
+
+-- name: DeleteChatSetting :exec
+DELETE FROM chat_settings
+WHERE chat_id = $1 AND key = $2;
+
+-- name: GetMediaFileID :one
+SELECT file_id
+FROM media_files
+WHERE hash = $1;
+
+-- name: SetMediaFileID :exec
+INSERT INTO media_files (hash, file_id)
+VALUES ($1, $2)
+ON CONFLICT (hash) DO UPDATE SET file_id = EXCLUDED.file_id;
+
+-- name: AddStickerUsage :exec
+INSERT INTO sticker_usage (sticker_id, set_name, usage_count)
+VALUES ($1, $2, 1)
+ON CONFLICT (sticker_id) DO UPDATE SET
+    set_name = EXCLUDED.set_name,
+    usage_count = sticker_usage.usage_count + 1;
+
+-- name: GetStickerSetUsage :many
+SELECT set_name, SUM(usage_count)::BIGINT AS usage_count
+FROM sticker_usage
+GROUP BY set_name;
+
+-- name: GetStickerSets :many
+SELECT name, enabled
+FROM sticker_sets
+ORDER BY name;
+
+-- name: UpsertStickerSet :exec
+INSERT INTO sticker_sets (name, enabled)
+VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET enabled = EXCLUDED.enabled;
+
+-- name: DeleteStickerSet :exec
+DELETE FROM sticker_sets
+WHERE name = $1;
+
+-- name: GetStickerExclusions :many
+SELECT set_name, sticker_id
+FROM sticker_exclusions
+ORDER BY set_name, sticker_id;
+
+-- name: AddStickerExclusion :exec
+INSERT INTO sticker_exclusions (set_name, sticker_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteStickerExclusions :exec
+DELETE FROM sticker_exclusions
+WHERE set_name = $1;
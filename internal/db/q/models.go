@@ -4,6 +4,17 @@
 
 package q
 
+type ChatSetting struct {
+	ChatID int64
+	Key    string
+	Value  string
+}
+
+type MediaFile struct {
+	Hash   string
+	FileID string
+}
+
 type Stat struct {
 	UserID            int64
 	ChatID            int64
@@ -12,6 +23,22 @@ type Stat struct {
 	LikvidirovanCount int64
 }
 
+type StickerExclusion struct {
+	SetName   string
+	StickerID string
+}
+
+type StickerSet struct {
+	Name    string
+	Enabled bool
+}
+
+type StickerUsage struct {
+	StickerID  string
+	SetName    string
+	UsageCount int64
+}
+
 type User struct {
 	ID            int64
 	DisplayedName string
@@ -10,49 +10,207 @@ import (
 )
 
 const addStats = `-- name: AddStats :exec
-UPDATE stats
-SET
-    svo_count = svo_count + ?,
-    zov_count = zov_count + ?,
-    likvidirovan_count = likvidirovan_count + ?
-WHERE
-    user_id = ? AND chat_id = ?
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = svo_count + excluded.svo_count,
+    zov_count = zov_count + excluded.zov_count,
+    likvidirovan_count = likvidirovan_count + excluded.likvidirovan_count
 `
 
 type AddStatsParams struct {
+	UserID            int64
+	ChatID            int64
 	SvoCount          int64
 	ZovCount          int64
 	LikvidirovanCount int64
-	UserID            int64
-	ChatID            int64
 }
 
 func (q *Queries) AddStats(ctx context.Context, arg AddStatsParams) error {
 	_, err := q.db.ExecContext(ctx, addStats,
+		arg.UserID,
+		arg.ChatID,
 		arg.SvoCount,
 		arg.ZovCount,
 		arg.LikvidirovanCount,
-		arg.UserID,
-		arg.ChatID,
 	)
 	return err
 }
 
-const createUser = `-- name: CreateUser :exec
-INSERT INTO users (id, displayed_name)
+const addStickerExclusion = `-- name: AddStickerExclusion :exec
+INSERT INTO sticker_exclusions (set_name, sticker_id)
 VALUES (?, ?)
+ON CONFLICT DO NOTHING
 `
 
-type CreateUserParams struct {
-	ID            int64
-	DisplayedName string
+type AddStickerExclusionParams struct {
+	SetName   string
+	StickerID string
+}
+
+func (q *Queries) AddStickerExclusion(ctx context.Context, arg AddStickerExclusionParams) error {
+	_, err := q.db.ExecContext(ctx, addStickerExclusion, arg.SetName, arg.StickerID)
+	return err
+}
+
+const addStickerUsage = `-- name: AddStickerUsage :exec
+INSERT INTO sticker_usage (sticker_id, set_name, usage_count)
+VALUES (?, ?, 1)
+ON CONFLICT (sticker_id) DO UPDATE SET
+    set_name = excluded.set_name,
+    usage_count = sticker_usage.usage_count + 1
+`
+
+type AddStickerUsageParams struct {
+	StickerID string
+	SetName   string
+}
+
+func (q *Queries) AddStickerUsage(ctx context.Context, arg AddStickerUsageParams) error {
+	_, err := q.db.ExecContext(ctx, addStickerUsage, arg.StickerID, arg.SetName)
+	return err
+}
+
+const deleteChatSetting = `-- name: DeleteChatSetting :exec
+DELETE FROM chat_settings
+WHERE chat_id = ? AND key = ?
+`
+
+type DeleteChatSettingParams struct {
+	ChatID int64
+	Key    string
+}
+
+func (q *Queries) DeleteChatSetting(ctx context.Context, arg DeleteChatSettingParams) error {
+	_, err := q.db.ExecContext(ctx, deleteChatSetting, arg.ChatID, arg.Key)
+	return err
+}
+
+const deleteStickerExclusions = `-- name: DeleteStickerExclusions :exec
+DELETE FROM sticker_exclusions
+WHERE set_name = ?
+`
+
+func (q *Queries) DeleteStickerExclusions(ctx context.Context, setName string) error {
+	_, err := q.db.ExecContext(ctx, deleteStickerExclusions, setName)
+	return err
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
-	_, err := q.db.ExecContext(ctx, createUser, arg.ID, arg.DisplayedName)
+const deleteStickerSet = `-- name: DeleteStickerSet :exec
+DELETE FROM sticker_sets
+WHERE name = ?
+`
+
+func (q *Queries) DeleteStickerSet(ctx context.Context, name string) error {
+	_, err := q.db.ExecContext(ctx, deleteStickerSet, name)
 	return err
 }
 
+const exportChatStats = `-- name: ExportChatStats :many
+SELECT
+    stats.user_id,
+    stats.chat_id,
+    users.displayed_name,
+    stats.svo_count,
+    stats.zov_count,
+    stats.likvidirovan_count
+FROM stats
+JOIN users ON users.id = stats.user_id
+WHERE stats.chat_id = ?
+ORDER BY stats.user_id
+`
+
+type ExportChatStatsRow struct {
+	UserID            int64
+	ChatID            int64
+	DisplayedName     string
+	SvoCount          int64
+	ZovCount          int64
+	LikvidirovanCount int64
+}
+
+func (q *Queries) ExportChatStats(ctx context.Context, chatID int64) ([]ExportChatStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, exportChatStats, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportChatStatsRow
+	for rows.Next() {
+		var i ExportChatStatsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ChatID,
+			&i.DisplayedName,
+			&i.SvoCount,
+			&i.ZovCount,
+			&i.LikvidirovanCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportStats = `-- name: ExportStats :many
+SELECT
+    stats.user_id,
+    stats.chat_id,
+    users.displayed_name,
+    stats.svo_count,
+    stats.zov_count,
+    stats.likvidirovan_count
+FROM stats
+JOIN users ON users.id = stats.user_id
+ORDER BY stats.chat_id, stats.user_id
+`
+
+type ExportStatsRow struct {
+	UserID            int64
+	ChatID            int64
+	DisplayedName     string
+	SvoCount          int64
+	ZovCount          int64
+	LikvidirovanCount int64
+}
+
+func (q *Queries) ExportStats(ctx context.Context) ([]ExportStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, exportStats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportStatsRow
+	for rows.Next() {
+		var i ExportStatsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ChatID,
+			&i.DisplayedName,
+			&i.SvoCount,
+			&i.ZovCount,
+			&i.LikvidirovanCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllChats = `-- name: GetAllChats :many
 SELECT DISTINCT chat_id
 FROM stats
@@ -81,15 +239,35 @@ func (q *Queries) GetAllChats(ctx context.Context) ([]int64, error) {
 	return items, nil
 }
 
+const getChatSetting = `-- name: GetChatSetting :one
+SELECT value
+FROM chat_settings
+WHERE chat_id = ? AND key = ?
+`
+
+type GetChatSettingParams struct {
+	ChatID int64
+	Key    string
+}
+
+func (q *Queries) GetChatSetting(ctx context.Context, arg GetChatSettingParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, getChatSetting, arg.ChatID, arg.Key)
+	var value string
+	err := row.Scan(&value)
+	return value, err
+}
+
 const getChatStats = `-- name: GetChatStats :many
-SELECT user_id, svo_count, zov_count, likvidirovan_count
+SELECT stats.user_id, users.displayed_name, stats.svo_count, stats.zov_count, stats.likvidirovan_count
 FROM stats
-WHERE chat_id = ? AND svo_count + zov_count > 0
-ORDER BY svo_count + zov_count DESC
+JOIN users ON users.id = stats.user_id
+WHERE stats.chat_id = ? AND stats.svo_count + stats.zov_count > 0
+ORDER BY stats.svo_count + stats.zov_count DESC
 `
 
 type GetChatStatsRow struct {
 	UserID            int64
+	DisplayedName     string
 	SvoCount          int64
 	ZovCount          int64
 	LikvidirovanCount int64
@@ -106,6 +284,7 @@ func (q *Queries) GetChatStats(ctx context.Context, chatID int64) ([]GetChatStat
 		var i GetChatStatsRow
 		if err := rows.Scan(
 			&i.UserID,
+			&i.DisplayedName,
 			&i.SvoCount,
 			&i.ZovCount,
 			&i.LikvidirovanCount,
@@ -123,6 +302,19 @@ func (q *Queries) GetChatStats(ctx context.Context, chatID int64) ([]GetChatStat
 	return items, nil
 }
 
+const getMediaFileID = `-- name: GetMediaFileID :one
+SELECT file_id
+FROM media_files
+WHERE hash = ?
+`
+
+func (q *Queries) GetMediaFileID(ctx context.Context, hash string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getMediaFileID, hash)
+	var file_id string
+	err := row.Scan(&file_id)
+	return file_id, err
+}
+
 const getStats = `-- name: GetStats :one
 SELECT
     COUNT(DISTINCT user_id) as total_users,
@@ -142,47 +334,240 @@ func (q *Queries) GetStats(ctx context.Context) (GetStatsRow, error) {
 	return i, err
 }
 
-const getUser = `-- name: GetUser :one
-SELECT displayed_name
-FROM users
-WHERE id = ?
-LIMIT 1
+const getStickerExclusions = `-- name: GetStickerExclusions :many
+SELECT set_name, sticker_id
+FROM sticker_exclusions
+ORDER BY set_name, sticker_id
+`
+
+func (q *Queries) GetStickerExclusions(ctx context.Context) ([]StickerExclusion, error) {
+	rows, err := q.db.QueryContext(ctx, getStickerExclusions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StickerExclusion
+	for rows.Next() {
+		var i StickerExclusion
+		if err := rows.Scan(&i.SetName, &i.StickerID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStickerSetUsage = `-- name: GetStickerSetUsage :many
+SELECT set_name, CAST(SUM(usage_count) AS INTEGER) AS usage_count
+FROM sticker_usage
+GROUP BY set_name
 `
 
-func (q *Queries) GetUser(ctx context.Context, id int64) (string, error) {
-	row := q.db.QueryRowContext(ctx, getUser, id)
-	var displayed_name string
-	err := row.Scan(&displayed_name)
-	return displayed_name, err
+type GetStickerSetUsageRow struct {
+	SetName    string
+	UsageCount int64
 }
 
-const initStat = `-- name: InitStat :exec
-INSERT OR IGNORE INTO stats (user_id, chat_id)
-VALUES (?, ?)
+func (q *Queries) GetStickerSetUsage(ctx context.Context) ([]GetStickerSetUsageRow, error) {
+	rows, err := q.db.QueryContext(ctx, getStickerSetUsage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStickerSetUsageRow
+	for rows.Next() {
+		var i GetStickerSetUsageRow
+		if err := rows.Scan(&i.SetName, &i.UsageCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStickerSets = `-- name: GetStickerSets :many
+SELECT name, enabled
+FROM sticker_sets
+ORDER BY name
 `
 
-type InitStatParams struct {
+func (q *Queries) GetStickerSets(ctx context.Context) ([]StickerSet, error) {
+	rows, err := q.db.QueryContext(ctx, getStickerSets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StickerSet
+	for rows.Next() {
+		var i StickerSet
+		if err := rows.Scan(&i.Name, &i.Enabled); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserStats = `-- name: GetUserStats :one
+SELECT svo_count, zov_count, likvidirovan_count
+FROM stats
+WHERE user_id = ? AND chat_id = ?
+`
+
+type GetUserStatsParams struct {
 	UserID int64
 	ChatID int64
 }
 
-func (q *Queries) InitStat(ctx context.Context, arg InitStatParams) error {
-	_, err := q.db.ExecContext(ctx, initStat, arg.UserID, arg.ChatID)
+type GetUserStatsRow struct {
+	SvoCount          int64
+	ZovCount          int64
+	LikvidirovanCount int64
+}
+
+func (q *Queries) GetUserStats(ctx context.Context, arg GetUserStatsParams) (GetUserStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserStats, arg.UserID, arg.ChatID)
+	var i GetUserStatsRow
+	err := row.Scan(&i.SvoCount, &i.ZovCount, &i.LikvidirovanCount)
+	return i, err
+}
+
+const maxStats = `-- name: MaxStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = MAX(svo_count, excluded.svo_count),
+    zov_count = MAX(zov_count, excluded.zov_count),
+    likvidirovan_count = MAX(likvidirovan_count, excluded.likvidirovan_count)
+`
+
+type MaxStatsParams struct {
+	UserID            int64
+	ChatID            int64
+	SvoCount          int64
+	ZovCount          int64
+	LikvidirovanCount int64
+}
+
+func (q *Queries) MaxStats(ctx context.Context, arg MaxStatsParams) error {
+	_, err := q.db.ExecContext(ctx, maxStats,
+		arg.UserID,
+		arg.ChatID,
+		arg.SvoCount,
+		arg.ZovCount,
+		arg.LikvidirovanCount,
+	)
 	return err
 }
 
-const updateUser = `-- name: UpdateUser :exec
-UPDATE users
-SET displayed_name = ?
-WHERE id = ?
+const replaceStats = `-- name: ReplaceStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = excluded.svo_count,
+    zov_count = excluded.zov_count,
+    likvidirovan_count = excluded.likvidirovan_count
 `
 
-type UpdateUserParams struct {
-	DisplayedName string
+type ReplaceStatsParams struct {
+	UserID            int64
+	ChatID            int64
+	SvoCount          int64
+	ZovCount          int64
+	LikvidirovanCount int64
+}
+
+func (q *Queries) ReplaceStats(ctx context.Context, arg ReplaceStatsParams) error {
+	_, err := q.db.ExecContext(ctx, replaceStats,
+		arg.UserID,
+		arg.ChatID,
+		arg.SvoCount,
+		arg.ZovCount,
+		arg.LikvidirovanCount,
+	)
+	return err
+}
+
+const setChatSetting = `-- name: SetChatSetting :exec
+INSERT INTO chat_settings (chat_id, key, value)
+VALUES (?, ?, ?)
+ON CONFLICT (chat_id, key) DO UPDATE SET value = excluded.value
+`
+
+type SetChatSettingParams struct {
+	ChatID int64
+	Key    string
+	Value  string
+}
+
+func (q *Queries) SetChatSetting(ctx context.Context, arg SetChatSettingParams) error {
+	_, err := q.db.ExecContext(ctx, setChatSetting, arg.ChatID, arg.Key, arg.Value)
+	return err
+}
+
+const setMediaFileID = `-- name: SetMediaFileID :exec
+INSERT INTO media_files (hash, file_id)
+VALUES (?, ?)
+ON CONFLICT (hash) DO UPDATE SET file_id = excluded.file_id
+`
+
+type SetMediaFileIDParams struct {
+	Hash   string
+	FileID string
+}
+
+func (q *Queries) SetMediaFileID(ctx context.Context, arg SetMediaFileIDParams) error {
+	_, err := q.db.ExecContext(ctx, setMediaFileID, arg.Hash, arg.FileID)
+	return err
+}
+
+const upsertStickerSet = `-- name: UpsertStickerSet :exec
+INSERT INTO sticker_sets (name, enabled)
+VALUES (?, ?)
+ON CONFLICT (name) DO UPDATE SET enabled = excluded.enabled
+`
+
+type UpsertStickerSetParams struct {
+	Name    string
+	Enabled bool
+}
+
+func (q *Queries) UpsertStickerSet(ctx context.Context, arg UpsertStickerSetParams) error {
+	_, err := q.db.ExecContext(ctx, upsertStickerSet, arg.Name, arg.Enabled)
+	return err
+}
+
+const upsertUser = `-- name: UpsertUser :exec
+INSERT INTO users (id, displayed_name)
+VALUES (?, ?)
+ON CONFLICT (id) DO UPDATE SET displayed_name = excluded.displayed_name
+WHERE users.displayed_name <> excluded.displayed_name
+`
+
+type UpsertUserParams struct {
 	ID            int64
+	DisplayedName string
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
-	_, err := q.db.ExecContext(ctx, updateUser, arg.DisplayedName, arg.ID)
+func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) error {
+	_, err := q.db.ExecContext(ctx, upsertUser, arg.ID, arg.DisplayedName)
 	return err
 }
@@ -1,36 +1,39 @@
--- name: CreateUser :exec
+-- name: UpsertUser :exec
 INSERT INTO users (id, displayed_name)
-VALUES (?, ?);
+VALUES (?, ?)
+ON CONFLICT (id) DO UPDATE SET displayed_name = excluded.displayed_name
+WHERE users.displayed_name <> excluded.displayed_name;
 
--- name: UpdateUser :exec
-UPDATE users
-SET displayed_name = ?
-WHERE id = ?;
+-- name: AddStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = svo_count + excluded.svo_count,
+    zov_count = zov_count + excluded.zov_count,
+    likvidirovan_count = likvidirovan_count + excluded.likvidirovan_count;
 
--- name: GetUser :one
-SELECT displayed_name
-FROM users
-WHERE id = ?
-LIMIT 1;
+-- name: ReplaceStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = excluded.svo_count,
+    zov_count = excluded.zov_count,
+    likvidirovan_count = excluded.likvidirovan_count;
 
--- name: InitStat :exec
-INSERT OR IGNORE INTO stats (user_id, chat_id)
-VALUES (?, ?);
+-- name: MaxStats :exec
+INSERT INTO stats (user_id, chat_id, svo_count, zov_count, likvidirovan_count)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_id, chat_id) DO UPDATE SET
+    svo_count = MAX(svo_count, excluded.svo_count),
+    zov_count = MAX(zov_count, excluded.zov_count),
+    likvidirovan_count = MAX(likvidirovan_count, excluded.likvidirovan_count);
 
 -- name: GetChatStats :many
-SELECT user_id, svo_count, zov_count, likvidirovan_count
+SELECT stats.user_id, users.displayed_name, stats.svo_count, stats.zov_count, stats.likvidirovan_count
 FROM stats
-WHERE chat_id = ? AND svo_count + zov_count > 0
-ORDER BY svo_count + zov_count DESC;
-
--- name: AddStats :exec
-UPDATE stats
-SET
-    svo_count = svo_count + ?,
-    zov_count = zov_count + ?,
-    likvidirovan_count = likvidirovan_count + ?
-WHERE
-    user_id = ? AND chat_id = ?;
+JOIN users ON users.id = stats.user_id
+WHERE stats.chat_id = ? AND stats.svo_count + stats.zov_count > 0
+ORDER BY stats.svo_count + stats.zov_count DESC;
 
 -- name: GetAllChats :many
 SELECT DISTINCT chat_id
@@ -41,3 +44,97 @@ SELECT
     COUNT(DISTINCT user_id) as total_users,
     COUNT(DISTINCT chat_id) as total_chats
 FROM stats;
+
+-- name: ExportStats :many
+SELECT
+    stats.user_id,
+    stats.chat_id,
+    users.displayed_name,
+    stats.svo_count,
+    stats.zov_count,
+    stats.likvidirovan_count
+FROM stats
+JOIN users ON users.id = stats.user_id
+ORDER BY stats.chat_id, stats.user_id;
+
+-- name: ExportChatStats :many
+SELECT
+    stats.user_id,
+    stats.chat_id,
+    users.displayed_name,
+    stats.svo_count,
+    stats.zov_count,
+    stats.likvidirovan_count
+FROM stats
+JOIN users ON users.id = stats.user_id
+WHERE stats.chat_id = ?
+ORDER BY stats.user_id;
+
+-- name: GetUserStats :one
+SELECT svo_count, zov_count, likvidirovan_count
+FROM stats
+WHERE user_id = ? AND chat_id = ?;
+
+-- name: GetChatSetting :one
+SELECT value
+FROM chat_settings
+WHERE chat_id = ? AND key = ?;
+
+-- name: SetChatSetting :exec
+INSERT INTO chat_settings (chat_id, key, value)
+VALUES (?, ?, ?)
+ON CONFLICT (chat_id, key) DO UPDATE SET value = excluded.value;
+
+-- name: DeleteChatSetting :exec
+DELETE FROM chat_settings
+WHERE chat_id = ? AND key = ?;
+
+-- name: GetMediaFileID :one
+SELECT file_id
+FROM media_files
+WHERE hash = ?;
+
+-- name: SetMediaFileID :exec
+INSERT INTO media_files (hash, file_id)
+VALUES (?, ?)
+ON CONFLICT (hash) DO UPDATE SET file_id = excluded.file_id;
+
+-- name: AddStickerUsage :exec
+INSERT INTO sticker_usage (sticker_id, set_name, usage_count)
+VALUES (?, ?, 1)
+ON CONFLICT (sticker_id) DO UPDATE SET
+    set_name = excluded.set_name,
+    usage_count = sticker_usage.usage_count + 1;
+
+-- name: GetStickerSetUsage :many
+SELECT set_name, CAST(SUM(usage_count) AS INTEGER) AS usage_count
+FROM sticker_usage
+GROUP BY set_name;
+
+-- name: GetStickerSets :many
+SELECT name, enabled
+FROM sticker_sets
+ORDER BY name;
+
+-- name: UpsertStickerSet :exec
+INSERT INTO sticker_sets (name, enabled)
+VALUES (?, ?)
+ON CONFLICT (name) DO UPDATE SET enabled = excluded.enabled;
+
+-- name: DeleteStickerSet :exec
+DELETE FROM sticker_sets
+WHERE name = ?;
+
+-- name: GetStickerExclusions :many
+SELECT set_name, sticker_id
+FROM sticker_exclusions
+ORDER BY set_name, sticker_id;
+
+-- name: AddStickerExclusion :exec
+INSERT INTO sticker_exclusions (set_name, sticker_id)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteStickerExclusions :exec
+DELETE FROM sticker_exclusions
+WHERE set_name = ?;
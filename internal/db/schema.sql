@@ -15,3 +15,34 @@ CREATE TABLE IF NOT EXISTS stats (
 );
 
 CREATE INDEX IF NOT EXISTS stats_chat_id_idx ON stats(chat_id);
+
+CREATE TABLE IF NOT EXISTS chat_settings (
+    chat_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+
+    PRIMARY KEY (chat_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS media_files (
+    hash TEXT NOT NULL PRIMARY KEY,
+    file_id TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sticker_usage (
+    sticker_id TEXT NOT NULL PRIMARY KEY,
+    set_name TEXT NOT NULL,
+    usage_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS sticker_sets (
+    name TEXT NOT NULL PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS sticker_exclusions (
+    set_name TEXT NOT NULL,
+    sticker_id TEXT NOT NULL,
+
+    PRIMARY KEY (set_name, sticker_id)
+);
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db/q"
+)
+
+func (db *DB) GetChatSetting(ctx context.Context, chatID int, key string) (string, bool, error) {
+	value, err := db.Queries.GetChatSetting(ctx, q.GetChatSettingParams{
+		ChatID: int64(chatID),
+		Key:    key,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("get chat setting: %w", err)
+	}
+	return value, true, nil
+}
+
+func (db *DB) SetChatSetting(ctx context.Context, chatID int, key, value string) error {
+	err := db.Queries.SetChatSetting(ctx, q.SetChatSettingParams{
+		ChatID: int64(chatID),
+		Key:    key,
+		Value:  value,
+	})
+	if err != nil {
+		return fmt.Errorf("set chat setting: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteChatSetting(ctx context.Context, chatID int, key string) error {
+	err := db.Queries.DeleteChatSetting(ctx, q.DeleteChatSettingParams{
+		ChatID: int64(chatID),
+		Key:    key,
+	})
+	if err != nil {
+		return fmt.Errorf("delete chat setting: %w", err)
+	}
+	return nil
+}
@@ -10,20 +10,21 @@ import (
 )
 
 type NamedStats struct {
-	UserID            int
-	ChatID            int
-	UserDisplayName   string
-	ZovCount          int
-	SvoCount          int
-	LikvidirovanCount int
+	UserID            int    `json:"user_id"`
+	ChatID            int    `json:"chat_id"`
+	UserDisplayName   string `json:"user_display_name"`
+	ZovCount          int    `json:"zov_count"`
+	SvoCount          int    `json:"svo_count"`
+	LikvidirovanCount int    `json:"likvidirovan_count"`
 }
 
 func (db *DB) IncreaseStats(ctx context.Context, stats NamedStats) error {
-	if stats.UserID == 777000 { // Telegram account
-		return nil
-	}
+	return db.IncreaseStatsBatch(ctx, []NamedStats{stats})
+}
 
-	tx, err := db.Begin()
+// IncreaseStatsBatch adds all increments in a single transaction.
+func (db *DB) IncreaseStatsBatch(ctx context.Context, batch []NamedStats) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
@@ -31,48 +32,32 @@ func (db *DB) IncreaseStats(ctx context.Context, stats NamedStats) error {
 		_ = tx.Rollback()
 	}()
 
-	displayedName, err := db.WithTx(tx).GetUser(ctx, int64(stats.UserID))
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("get user %d: %w", stats.UserID, err)
-	}
+	queries := db.WithTx(tx)
+	for _, stats := range batch {
+		if stats.UserID == TelegramUserID {
+			continue
+		}
 
-	if errors.Is(err, sql.ErrNoRows) {
-		err := db.WithTx(tx).CreateUser(ctx, q.CreateUserParams{
+		err := queries.UpsertUser(ctx, q.UpsertUserParams{
 			ID:            int64(stats.UserID),
 			DisplayedName: stats.UserDisplayName,
 		})
 		if err != nil {
-			return fmt.Errorf("create user: %w", err)
+			return fmt.Errorf("upsert user %d: %w", stats.UserID, err)
 		}
-	} else if displayedName != stats.UserDisplayName {
-		err := db.WithTx(tx).UpdateUser(ctx, q.UpdateUserParams{
-			ID:            int64(stats.UserID),
-			DisplayedName: stats.UserDisplayName,
+
+		err = queries.AddStats(ctx, q.AddStatsParams{
+			UserID:            int64(stats.UserID),
+			ChatID:            int64(stats.ChatID),
+			SvoCount:          int64(stats.SvoCount),
+			ZovCount:          int64(stats.ZovCount),
+			LikvidirovanCount: int64(stats.LikvidirovanCount),
 		})
 		if err != nil {
-			return fmt.Errorf("update displayed name: %w", err)
+			return fmt.Errorf("add stats: %w", err)
 		}
 	}
 
-	err = db.WithTx(tx).InitStat(ctx, q.InitStatParams{
-		UserID: int64(stats.UserID),
-		ChatID: int64(stats.ChatID),
-	})
-	if err != nil {
-		return fmt.Errorf("init stat: %w", err)
-	}
-
-	err = db.WithTx(tx).AddStats(ctx, q.AddStatsParams{
-		UserID:            int64(stats.UserID),
-		ChatID:            int64(stats.ChatID),
-		SvoCount:          int64(stats.SvoCount),
-		ZovCount:          int64(stats.ZovCount),
-		LikvidirovanCount: int64(stats.LikvidirovanCount),
-	})
-	if err != nil {
-		return fmt.Errorf("add stats: %w", err)
-	}
-
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit changes: %w", err)
 	}
@@ -81,14 +66,6 @@ func (db *DB) IncreaseStats(ctx context.Context, stats NamedStats) error {
 }
 
 func (db *DB) RetrieveStats(ctx context.Context, chatID int) ([]NamedStats, error) {
-	tx, err := db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("begin transaction: %w", err)
-	}
-	defer func() {
-		_ = tx.Rollback()
-	}()
-
 	stats, err := db.GetChatStats(ctx, int64(chatID))
 	if err != nil {
 		return nil, fmt.Errorf("get chat stats: %w", err)
@@ -96,23 +73,35 @@ func (db *DB) RetrieveStats(ctx context.Context, chatID int) ([]NamedStats, erro
 
 	namedStats := make([]NamedStats, 0, len(stats))
 	for _, stat := range stats {
-		displayedName, err := db.GetUser(ctx, stat.UserID)
-		if err != nil {
-			return nil, fmt.Errorf("get user %d: %w", stat.UserID, err)
-		}
 		namedStats = append(namedStats, NamedStats{
 			UserID:            int(stat.UserID),
 			ChatID:            chatID,
-			UserDisplayName:   displayedName,
+			UserDisplayName:   stat.DisplayedName,
 			SvoCount:          int(stat.SvoCount),
 			ZovCount:          int(stat.ZovCount),
 			LikvidirovanCount: int(stat.LikvidirovanCount),
 		})
 	}
+	return namedStats, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("commit changes: %w", err)
+// GetUserStats returns stats of the user in the chat, which are zero if the user
+// has never sent a trigger there.
+func (db *DB) GetUserStats(ctx context.Context, userID, chatID int) (NamedStats, error) {
+	stats := NamedStats{UserID: userID, ChatID: chatID}
+	row, err := db.Queries.GetUserStats(ctx, q.GetUserStatsParams{
+		UserID: int64(userID),
+		ChatID: int64(chatID),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("get user stats: %w", err)
 	}
 
-	return namedStats, nil
+	stats.SvoCount = int(row.SvoCount)
+	stats.ZovCount = int(row.ZovCount)
+	stats.LikvidirovanCount = int(row.LikvidirovanCount)
+	return stats, nil
 }
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db/q"
+)
+
+// StickerSet is a sticker set managed with admin commands. Sets defined in config
+// are stored only after they are removed or added again.
+type StickerSet struct {
+	Name    string
+	Enabled bool
+}
+
+func (db *DB) IncreaseStickerUsage(ctx context.Context, setName, stickerID string) error {
+	err := db.Queries.AddStickerUsage(ctx, q.AddStickerUsageParams{
+		StickerID: stickerID,
+		SetName:   setName,
+	})
+	if err != nil {
+		return fmt.Errorf("add sticker usage: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetStickerSetUsage(ctx context.Context) (map[string]int, error) {
+	rows, err := db.Queries.GetStickerSetUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get sticker set usage: %w", err)
+	}
+
+	usage := make(map[string]int, len(rows))
+	for _, row := range rows {
+		usage[row.SetName] = int(row.UsageCount)
+	}
+	return usage, nil
+}
+
+func (db *DB) GetStickerSets(ctx context.Context) ([]StickerSet, error) {
+	sets, err := db.Queries.GetStickerSets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get sticker sets: %w", err)
+	}
+
+	result := make([]StickerSet, 0, len(sets))
+	for _, set := range sets {
+		result = append(result, StickerSet{
+			Name:    set.Name,
+			Enabled: set.Enabled,
+		})
+	}
+	return result, nil
+}
+
+func (db *DB) SetStickerSetEnabled(ctx context.Context, name string, enabled bool) error {
+	err := db.Queries.UpsertStickerSet(ctx, q.UpsertStickerSetParams{
+		Name:    name,
+		Enabled: enabled,
+	})
+	if err != nil {
+		return fmt.Errorf("upsert sticker set: %w", err)
+	}
+	return nil
+}
+
+// DeleteStickerSet deletes the set along with its excluded stickers in a single transaction.
+func (db *DB) DeleteStickerSet(ctx context.Context, name string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	queries := db.WithTx(tx)
+	if err := queries.DeleteStickerExclusions(ctx, name); err != nil {
+		return fmt.Errorf("delete sticker exclusions: %w", err)
+	}
+	if err := queries.DeleteStickerSet(ctx, name); err != nil {
+		return fmt.Errorf("delete sticker set: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit changes: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetExcludedStickers(ctx context.Context) (map[string][]string, error) {
+	exclusions, err := db.Queries.GetStickerExclusions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get sticker exclusions: %w", err)
+	}
+
+	excluded := make(map[string][]string)
+	for _, exclusion := range exclusions {
+		excluded[exclusion.SetName] = append(excluded[exclusion.SetName], exclusion.StickerID)
+	}
+	return excluded, nil
+}
+
+func (db *DB) ExcludeSticker(ctx context.Context, setName, stickerID string) error {
+	err := db.Queries.AddStickerExclusion(ctx, q.AddStickerExclusionParams{
+		SetName:   setName,
+		StickerID: stickerID,
+	})
+	if err != nil {
+		return fmt.Errorf("add sticker exclusion: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+)
+
+// Storage is implemented by every supported database backend.
+type Storage interface {
+	IncreaseStats(ctx context.Context, stats NamedStats) error
+	IncreaseStatsBatch(ctx context.Context, batch []NamedStats) error
+	RetrieveStats(ctx context.Context, chatID int) ([]NamedStats, error)
+	GetUserStats(ctx context.Context, userID, chatID int) (NamedStats, error)
+	GetAllChats(ctx context.Context) (chatIDs []int, _ error)
+	GetStats(ctx context.Context) (AggregatedStats, error)
+
+	ExportStats(ctx context.Context, chatID int) ([]NamedStats, error)
+	ImportStats(ctx context.Context, stats []NamedStats, strategy MergeStrategy) error
+
+	// GetChatSetting returns false if the setting is not set for the chat.
+	GetChatSetting(ctx context.Context, chatID int, key string) (value string, ok bool, _ error)
+	SetChatSetting(ctx context.Context, chatID int, key, value string) error
+	DeleteChatSetting(ctx context.Context, chatID int, key string) error
+
+	// GetMediaFileID returns Telegram file ID of a local media file uploaded earlier,
+	// identified by hash of its contents.
+	GetMediaFileID(ctx context.Context, hash string) (fileID string, ok bool, _ error)
+	SetMediaFileID(ctx context.Context, hash, fileID string) error
+
+	// IncreaseStickerUsage counts one more use of the sticker, identified by its file unique ID.
+	IncreaseStickerUsage(ctx context.Context, setName, stickerID string) error
+	// GetStickerSetUsage returns the number of sent stickers by set name.
+	GetStickerSetUsage(ctx context.Context) (map[string]int, error)
+
+	// GetStickerSets returns sticker sets added or disabled with admin commands.
+	GetStickerSets(ctx context.Context) ([]StickerSet, error)
+	SetStickerSetEnabled(ctx context.Context, name string, enabled bool) error
+	// DeleteStickerSet deletes the set along with its excluded stickers.
+	DeleteStickerSet(ctx context.Context, name string) error
+	// GetExcludedStickers returns file unique IDs of excluded stickers by set name.
+	GetExcludedStickers(ctx context.Context) (map[string][]string, error)
+	ExcludeSticker(ctx context.Context, setName, stickerID string) error
+
+	Close() error
+}
+
+type AggregatedStats struct {
+	TotalUsers int
+	TotalChats int
+}
+
+// TelegramUserID is the service account Telegram uses for channel posts and
+// notifications. Stats are never collected for it.
+const TelegramUserID = 777000
+
+var _ Storage = (*DB)(nil)
+
+// AsSqlite returns the sqlite database backing storage, looking through wrappers
+// such as BufferedStorage.
+func AsSqlite(storage Storage) (*DB, bool) {
+	for {
+		switch s := storage.(type) {
+		case *DB:
+			return s, true
+		case interface{ Unwrap() Storage }:
+			storage = s.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
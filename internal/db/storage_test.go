@@ -0,0 +1,61 @@
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+	"github.com/LeKSuS-04/svoi-bot/internal/db/storagetest"
+)
+
+func openSqlite(t *testing.T) *db.DB {
+	storage, err := db.NewDB(filepath.Join(t.TempDir(), "svoi.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func TestSqliteStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) db.Storage {
+		return openSqlite(t)
+	})
+}
+
+func TestBufferedStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) db.Storage {
+		return db.NewBufferedStorage(openSqlite(t), time.Hour)
+	})
+}
+
+func TestDeleteStickerSetAtomic(t *testing.T) {
+	ctx := context.Background()
+	storage := openSqlite(t)
+	if err := storage.SetStickerSetEnabled(ctx, "set", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.ExcludeSticker(ctx, "set", "sticker"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the second statement, after exclusions are deleted.
+	_, err := storage.ExecContext(ctx, `CREATE TRIGGER fail_delete BEFORE DELETE ON sticker_sets
+		BEGIN SELECT RAISE(ABORT, 'delete failed'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DeleteStickerSet(ctx, "set"); err == nil {
+		t.Fatal("DeleteStickerSet() succeeded despite failing delete")
+	}
+
+	excluded, err := storage.GetExcludedStickers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(excluded["set"], []string{"sticker"}) {
+		t.Errorf("excluded stickers are %v after failed delete, want them kept", excluded["set"])
+	}
+}
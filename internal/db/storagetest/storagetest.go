@@ -0,0 +1,362 @@
+// Package storagetest is a contract test suite that every db.Storage implementation
+// must pass.
+package storagetest
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/db"
+)
+
+// Run runs the suite against storages returned by open. Every test gets a new empty
+// storage, which is closed when the test finishes.
+func Run(t *testing.T, open func(t *testing.T) db.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, storage db.Storage)
+	}{
+		{"IncreaseStats", testIncreaseStats},
+		{"IncreaseStatsBatch", testIncreaseStatsBatch},
+		{"TelegramUser", testTelegramUser},
+		{"RetrieveStats", testRetrieveStats},
+		{"GetUserStats", testGetUserStats},
+		{"GetAllChats", testGetAllChats},
+		{"GetStats", testGetStats},
+		{"ExportStats", testExportStats},
+		{"ImportStats", testImportStats},
+		{"ChatSettings", testChatSettings},
+		{"MediaFileID", testMediaFileID},
+		{"StickerUsage", testStickerUsage},
+		{"StickerSets", testStickerSets},
+		{"ExcludedStickers", testExcludedStickers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := open(t)
+			t.Cleanup(func() {
+				if err := storage.Close(); err != nil {
+					t.Errorf("close storage: %v", err)
+				}
+			})
+			tt.test(t, storage)
+		})
+	}
+}
+
+func stats(userID, chatID int, name string, svo, zov, likvidirovan int) db.NamedStats {
+	return db.NamedStats{
+		UserID:            userID,
+		ChatID:            chatID,
+		UserDisplayName:   name,
+		SvoCount:          svo,
+		ZovCount:          zov,
+		LikvidirovanCount: likvidirovan,
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertStats(t *testing.T, got, want []db.NamedStats) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("stats mismatch:\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func testIncreaseStats(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.IncreaseStats(ctx, stats(1, 10, "alice", 1, 0, 0)))
+	must(t, storage.IncreaseStats(ctx, stats(1, 10, "alice", 2, 3, 1)))
+
+	got, err := storage.RetrieveStats(ctx, 10)
+	must(t, err)
+	assertStats(t, got, []db.NamedStats{stats(1, 10, "alice", 3, 3, 1)})
+}
+
+func testIncreaseStatsBatch(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.IncreaseStatsBatch(ctx, []db.NamedStats{
+		stats(1, 10, "alice", 1, 0, 0),
+		stats(2, 10, "bob", 0, 2, 0),
+		stats(1, 10, "alice", 1, 0, 0),
+	}))
+	// The latest display name wins.
+	must(t, storage.IncreaseStatsBatch(ctx, []db.NamedStats{stats(2, 10, "robert", 0, 1, 0)}))
+
+	got, err := storage.RetrieveStats(ctx, 10)
+	must(t, err)
+	assertStats(t, got, []db.NamedStats{
+		stats(2, 10, "robert", 0, 3, 0),
+		stats(1, 10, "alice", 2, 0, 0),
+	})
+
+	must(t, storage.IncreaseStatsBatch(ctx, nil))
+}
+
+func testTelegramUser(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.IncreaseStats(ctx, stats(db.TelegramUserID, 10, "Telegram", 1, 1, 0)))
+
+	got, err := storage.RetrieveStats(ctx, 10)
+	must(t, err)
+	assertStats(t, got, []db.NamedStats{})
+}
+
+func testRetrieveStats(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.IncreaseStatsBatch(ctx, []db.NamedStats{
+		stats(1, 10, "alice", 1, 0, 0),
+		stats(2, 10, "bob", 3, 2, 0),
+		stats(3, 10, "carol", 0, 2, 0),
+		// Users with only likvidirovan are not shown in chat stats.
+		stats(4, 10, "dave", 0, 0, 5),
+		stats(1, 20, "alice", 7, 0, 0),
+	}))
+
+	got, err := storage.RetrieveStats(ctx, 10)
+	must(t, err)
+	assertStats(t, got, []db.NamedStats{
+		stats(2, 10, "bob", 3, 2, 0),
+		stats(3, 10, "carol", 0, 2, 0),
+		stats(1, 10, "alice", 1, 0, 0),
+	})
+
+	got, err = storage.RetrieveStats(ctx, 30)
+	must(t, err)
+	assertStats(t, got, []db.NamedStats{})
+}
+
+func testGetUserStats(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.IncreaseStats(ctx, stats(1, 10, "alice", 1, 2, 3)))
+
+	got, err := storage.GetUserStats(ctx, 1, 10)
+	must(t, err)
+	if want := stats(1, 10, "", 1, 2, 3); got != want {
+		t.Errorf("GetUserStats() = %+v, want %+v", got, want)
+	}
+
+	got, err = storage.GetUserStats(ctx, 1, 20)
+	must(t, err)
+	if want := stats(1, 20, "", 0, 0, 0); got != want {
+		t.Errorf("GetUserStats() of unknown chat = %+v, want %+v", got, want)
+	}
+}
+
+func testGetAllChats(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	chats, err := storage.GetAllChats(ctx)
+	must(t, err)
+	if len(chats) != 0 {
+		t.Errorf("GetAllChats() of empty storage = %v, want none", chats)
+	}
+
+	must(t, storage.IncreaseStatsBatch(ctx, []db.NamedStats{
+		stats(1, 10, "alice", 1, 0, 0),
+		stats(2, 10, "bob", 1, 0, 0),
+		stats(1, -20, "alice", 1, 0, 0),
+	}))
+	chats, err = storage.GetAllChats(ctx)
+	must(t, err)
+	slices.Sort(chats)
+	if want := []int{-20, 10}; !slices.Equal(chats, want) {
+		t.Errorf("GetAllChats() = %v, want %v", chats, want)
+	}
+}
+
+func testGetStats(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.IncreaseStatsBatch(ctx, []db.NamedStats{
+		stats(1, 10, "alice", 1, 0, 0),
+		stats(2, 10, "bob", 1, 0, 0),
+		stats(1, 20, "alice", 1, 0, 0),
+	}))
+
+	got, err := storage.GetStats(ctx)
+	must(t, err)
+	if want := (db.AggregatedStats{TotalUsers: 2, TotalChats: 2}); got != want {
+		t.Errorf("GetStats() = %+v, want %+v", got, want)
+	}
+}
+
+func testExportStats(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.IncreaseStatsBatch(ctx, []db.NamedStats{
+		stats(2, 20, "bob", 1, 0, 0),
+		stats(1, 20, "alice", 0, 1, 0),
+		stats(1, 10, "alice", 0, 0, 1),
+	}))
+
+	got, err := storage.ExportStats(ctx, 0)
+	must(t, err)
+	assertStats(t, got, []db.NamedStats{
+		stats(1, 10, "alice", 0, 0, 1),
+		stats(1, 20, "alice", 0, 1, 0),
+		stats(2, 20, "bob", 1, 0, 0),
+	})
+
+	got, err = storage.ExportStats(ctx, 20)
+	must(t, err)
+	assertStats(t, got, []db.NamedStats{
+		stats(1, 20, "alice", 0, 1, 0),
+		stats(2, 20, "bob", 1, 0, 0),
+	})
+}
+
+func testImportStats(t *testing.T, storage db.Storage) {
+	tests := []struct {
+		strategy db.MergeStrategy
+		want     db.NamedStats
+	}{
+		{db.MergeSum, stats(1, 10, "alice", 5, 5, 1)},
+		{db.MergeReplace, stats(1, 10, "alice", 1, 4, 0)},
+		{db.MergeMax, stats(1, 10, "alice", 4, 4, 1)},
+	}
+	ctx := context.Background()
+	for i, tt := range tests {
+		chatID := tt.want.ChatID + i
+		want := tt.want
+		want.ChatID = chatID
+
+		must(t, storage.IncreaseStats(ctx, stats(1, chatID, "old name", 4, 1, 1)))
+		must(t, storage.ImportStats(ctx, []db.NamedStats{
+			stats(1, chatID, "alice", 1, 4, 0),
+			stats(2, chatID, "bob", 2, 0, 0),
+		}, tt.strategy))
+
+		got, err := storage.ExportStats(ctx, chatID)
+		must(t, err)
+		assertStats(t, got, []db.NamedStats{want, stats(2, chatID, "bob", 2, 0, 0)})
+	}
+
+	if err := storage.ImportStats(ctx, []db.NamedStats{stats(3, 10, "carol", 1, 0, 0)}, "unknown"); err == nil {
+		t.Error("ImportStats() with unknown strategy succeeded")
+	}
+	got, err := storage.GetUserStats(ctx, 3, 10)
+	must(t, err)
+	if want := stats(3, 10, "", 0, 0, 0); got != want {
+		t.Errorf("failed import was not rolled back: got %+v", got)
+	}
+}
+
+func testChatSettings(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	get := func(chatID int, key string) (string, bool) {
+		t.Helper()
+		value, ok, err := storage.GetChatSetting(ctx, chatID, key)
+		must(t, err)
+		return value, ok
+	}
+
+	if value, ok := get(10, "language"); ok {
+		t.Errorf("unset setting = %q, want none", value)
+	}
+
+	must(t, storage.SetChatSetting(ctx, 10, "language", "en"))
+	must(t, storage.SetChatSetting(ctx, 10, "language", "uk"))
+	must(t, storage.SetChatSetting(ctx, 20, "language", "ru"))
+	if value, ok := get(10, "language"); !ok || value != "uk" {
+		t.Errorf("setting = %q, %t, want %q", value, ok, "uk")
+	}
+	if value, ok := get(20, "language"); !ok || value != "ru" {
+		t.Errorf("setting of other chat = %q, %t, want %q", value, ok, "ru")
+	}
+
+	must(t, storage.DeleteChatSetting(ctx, 10, "language"))
+	must(t, storage.DeleteChatSetting(ctx, 10, "unknown"))
+	if value, ok := get(10, "language"); ok {
+		t.Errorf("deleted setting = %q, want none", value)
+	}
+	if _, ok := get(20, "language"); !ok {
+		t.Error("setting of other chat was deleted")
+	}
+}
+
+func testMediaFileID(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	if fileID, ok, err := storage.GetMediaFileID(ctx, "hash"); err != nil || ok {
+		t.Errorf("GetMediaFileID() of unknown hash = %q, %t, %v, want none", fileID, ok, err)
+	}
+
+	must(t, storage.SetMediaFileID(ctx, "hash", "old"))
+	must(t, storage.SetMediaFileID(ctx, "hash", "new"))
+	fileID, ok, err := storage.GetMediaFileID(ctx, "hash")
+	must(t, err)
+	if !ok || fileID != "new" {
+		t.Errorf("GetMediaFileID() = %q, %t, want %q", fileID, ok, "new")
+	}
+}
+
+func testStickerUsage(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	usage, err := storage.GetStickerSetUsage(ctx)
+	must(t, err)
+	if len(usage) != 0 {
+		t.Errorf("GetStickerSetUsage() of empty storage = %v, want none", usage)
+	}
+
+	must(t, storage.IncreaseStickerUsage(ctx, "first", "a"))
+	must(t, storage.IncreaseStickerUsage(ctx, "first", "a"))
+	must(t, storage.IncreaseStickerUsage(ctx, "first", "b"))
+	must(t, storage.IncreaseStickerUsage(ctx, "second", "c"))
+
+	usage, err = storage.GetStickerSetUsage(ctx)
+	must(t, err)
+	if want := map[string]int{"first": 3, "second": 1}; !maps.Equal(usage, want) {
+		t.Errorf("GetStickerSetUsage() = %v, want %v", usage, want)
+	}
+}
+
+func testStickerSets(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.SetStickerSetEnabled(ctx, "second", true))
+	must(t, storage.SetStickerSetEnabled(ctx, "first", true))
+	must(t, storage.SetStickerSetEnabled(ctx, "first", false))
+
+	sets, err := storage.GetStickerSets(ctx)
+	must(t, err)
+	want := []db.StickerSet{{Name: "first", Enabled: false}, {Name: "second", Enabled: true}}
+	if !slices.Equal(sets, want) {
+		t.Errorf("GetStickerSets() = %+v, want %+v", sets, want)
+	}
+
+	must(t, storage.DeleteStickerSet(ctx, "first"))
+	sets, err = storage.GetStickerSets(ctx)
+	must(t, err)
+	if want := []db.StickerSet{{Name: "second", Enabled: true}}; !slices.Equal(sets, want) {
+		t.Errorf("GetStickerSets() after delete = %+v, want %+v", sets, want)
+	}
+}
+
+func testExcludedStickers(t *testing.T, storage db.Storage) {
+	ctx := context.Background()
+	must(t, storage.SetStickerSetEnabled(ctx, "first", true))
+	must(t, storage.SetStickerSetEnabled(ctx, "second", true))
+	must(t, storage.ExcludeSticker(ctx, "first", "b"))
+	must(t, storage.ExcludeSticker(ctx, "first", "a"))
+	must(t, storage.ExcludeSticker(ctx, "first", "a"))
+	must(t, storage.ExcludeSticker(ctx, "second", "c"))
+
+	excluded, err := storage.GetExcludedStickers(ctx)
+	must(t, err)
+	want := map[string][]string{"first": {"a", "b"}, "second": {"c"}}
+	if !maps.EqualFunc(excluded, want, slices.Equal) {
+		t.Errorf("GetExcludedStickers() = %v, want %v", excluded, want)
+	}
+
+	must(t, storage.DeleteStickerSet(ctx, "first"))
+	excluded, err = storage.GetExcludedStickers(ctx)
+	must(t, err)
+	want = map[string][]string{"second": {"c"}}
+	if !maps.EqualFunc(excluded, want, slices.Equal) {
+		t.Errorf("GetExcludedStickers() after deleting set = %v, want %v", excluded, want)
+	}
+}
@@ -0,0 +1,146 @@
+// Package detector finds trigger words in messages, seeing through
+// homoglyphs, transliteration and common obfuscation.
+package detector
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type Type string
+
+const (
+	Svo Type = "svo"
+	Zov Type = "zov"
+)
+
+var stems = []struct {
+	typ  Type
+	stem string
+}{
+	{typ: Svo, stem: "сво"},
+	{typ: Zov, stem: "зов"},
+}
+
+// DefaultWhitelist contains common words that start with a trigger but are not one.
+var DefaultWhitelist = []string{
+	"свой", "свои", "своя", "свое", "свою",
+	"свод", "свор", "свобод",
+	"зову", "зове",
+}
+
+type Config struct {
+	// Whitelist entries are matched against normalized words as prefixes,
+	// in addition to DefaultWhitelist.
+	Whitelist []string `yaml:"whitelist"`
+}
+
+type Match struct {
+	Type Type
+	// Quote is the part of the original text that forms the trigger.
+	Quote string
+	// RuneOffset and RuneLength locate the quote in the original text in runes.
+	RuneOffset int
+	RuneLength int
+	// UTF16Offset locates the quote in UTF-16 code units, as expected by Telegram.
+	UTF16Offset int
+	// Exact is true when the whole word is the trigger rather than starting with it.
+	Exact bool
+}
+
+type Detector struct {
+	whitelist []string
+}
+
+func New(config Config) *Detector {
+	d := &Detector{}
+	for _, entry := range slices.Concat(DefaultWhitelist, config.Whitelist) {
+		if normalized := normalizeWord(entry); normalized != "" {
+			d.whitelist = append(d.whitelist, normalized)
+		}
+	}
+	return d
+}
+
+// Find returns all triggers in text in order of appearance.
+func (d *Detector) Find(text string) (matches []Match) {
+	for _, w := range splitWords(text) {
+		if m, ok := d.match(text, w); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+func (d *Detector) match(text string, w word) (Match, bool) {
+	if !w.hasLetters {
+		return Match{}, false
+	}
+
+	normalized := w.String()
+	for _, s := range stems {
+		if !strings.HasPrefix(normalized, s.stem) {
+			continue
+		}
+		if d.whitelisted(normalized) {
+			return Match{}, false
+		}
+
+		stemLength := utf8.RuneCountInString(s.stem)
+		start := w.letters[0].start
+		end := w.letters[stemLength-1].end
+		quote := text[start:end]
+		return Match{
+			Type:        s.typ,
+			Quote:       quote,
+			RuneOffset:  utf8.RuneCountInString(text[:start]),
+			RuneLength:  utf8.RuneCountInString(quote),
+			UTF16Offset: utf16Length(text[:start]),
+			Exact:       len(w.letters) == stemLength,
+		}, true
+	}
+	return Match{}, false
+}
+
+func (d *Detector) whitelisted(normalized string) bool {
+	for _, entry := range d.whitelist {
+		if strings.HasPrefix(normalized, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAIRespondable reports whether text is a meaningful message mentioning a trigger
+// rather than a trigger spam, so that it is worth generating an AI response for it.
+func (d *Detector) IsAIRespondable(text string) bool {
+	if len(strings.Fields(text)) < 5 {
+		return false
+	}
+
+	exactCount := 0
+	containsCount := 0
+	normalWordCount := 0
+	for _, w := range splitWords(text) {
+		m, ok := d.match(text, w)
+		switch {
+		case ok && m.Exact:
+			exactCount++
+		case ok:
+			containsCount++
+		default:
+			normalWordCount++
+		}
+	}
+
+	return normalWordCount >= 5 && exactCount <= 2 && containsCount <= 2
+}
+
+func utf16Length(s string) (n int) {
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
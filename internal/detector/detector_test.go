@@ -0,0 +1,123 @@
+package detector
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// want are quotes of found triggers along with their types.
+		want []Match
+	}{
+		{"plain", "сво", []Match{{Type: Svo, Quote: "сво"}}},
+		{"upper case", "СВО", []Match{{Type: Svo, Quote: "СВО"}}},
+		{"zov", "гойда ЗОВ", []Match{{Type: Zov, Quote: "ЗОВ"}}},
+		{"prefix", "сволочь", []Match{{Type: Svo, Quote: "сво"}}},
+		{"several", "сво и зов", []Match{{Type: Svo, Quote: "сво"}, {Type: Zov, Quote: "зов"}}},
+		{"inside word", "массовый", nil},
+		{"punctuation", "сво!", []Match{{Type: Svo, Quote: "сво"}}},
+
+		{"latin homoglyphs", "CBO", []Match{{Type: Svo, Quote: "CBO"}}},
+		{"mixed homoglyphs", "сVо", []Match{{Type: Svo, Quote: "сVо"}}},
+		{"transliteration", "svo", []Match{{Type: Svo, Quote: "svo"}}},
+		{"greek homoglyphs", "ϲβο", []Match{{Type: Svo, Quote: "ϲβο"}}},
+		{"digit homoglyphs", "3OV", []Match{{Type: Zov, Quote: "3OV"}}},
+		{"fullwidth", "ＳＶＯ", []Match{{Type: Svo, Quote: "ＳＶＯ"}}},
+		{"digits only", "380", nil},
+		{"repeated letters", "СССВВВООО", []Match{{Type: Svo, Quote: "СССВВВООО"}}},
+
+		{"zero-width space", "С​В​О", []Match{{Type: Svo, Quote: "С​В​О"}}},
+		{"zero-width joiner", "з‍о‍в", []Match{{Type: Zov, Quote: "з‍о‍в"}}},
+		{"soft hyphen", "с­во", []Match{{Type: Svo, Quote: "с­во"}}},
+		{"combining marks", "с̶в̶о̶", []Match{{Type: Svo, Quote: "с̶в̶о"}}},
+
+		{"flag separators", "С🇷В🇷О", []Match{{Type: Svo, Quote: "С🇷В🇷О"}}},
+		{"emoji separators", "З🔥О🔥В", []Match{{Type: Zov, Quote: "З🔥О🔥В"}}},
+		{"emoji with variation selector", "С❤️В❤️О", []Match{{Type: Svo, Quote: "С❤️В❤️О"}}},
+		{"dots", "С.В.О", []Match{{Type: Svo, Quote: "С.В.О"}}},
+		{"dashes and stars", "с-в*о", []Match{{Type: Svo, Quote: "с-в*о"}}},
+
+		{"sentence dot", "это сво.Дальше", []Match{{Type: Svo, Quote: "сво"}}},
+		{"sentence dot in lower case", "это сво.дальше", []Match{{Type: Svo, Quote: "сво"}}},
+		{"dots before sentence", "с.в.о.Дальше", []Match{{Type: Svo, Quote: "с.в.о"}}},
+		{"sentence dot before trigger", "конец.Сво", []Match{{Type: Svo, Quote: "Сво"}}},
+		{"trailing dot", "С.В.О.", []Match{{Type: Svo, Quote: "С.В.О"}}},
+
+		{"whitelist", "свой своя свободу", nil},
+		{"whitelist with homoglyphs", "CBOй", nil},
+		{"whitelist obfuscated", "С.В.О.Б.О.Д.А", nil},
+		{"whitelisted zov", "зову", nil},
+		{"config whitelist", "сволочь сводка", []Match{{Type: Svo, Quote: "сво"}}},
+	}
+
+	d := New(Config{Whitelist: []string{"сводк"}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Match
+			for _, m := range d.Find(tt.text) {
+				got = append(got, Match{Type: m.Type, Quote: m.Quote})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Find(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindOffsets(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		runeOffset  int
+		runeLength  int
+		utf16Offset int
+		exact       bool
+	}{
+		{"start", "сво", 0, 3, 0, true},
+		{"after words", "это сво", 4, 3, 4, true},
+		{"prefix", "ну сволочь", 3, 3, 3, false},
+		{"after emoji", "🔥 сво", 2, 3, 3, true},
+		{"after several emoji", "🔥🔥🔥 зов", 4, 3, 7, true},
+		{"emoji inside", "С🇷В🇷О", 0, 5, 0, true},
+		{"emoji inside after emoji", "😀 С🇷В🇷О", 2, 5, 3, true},
+	}
+
+	d := New(Config{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := d.Find(tt.text)
+			if len(matches) != 1 {
+				t.Fatalf("Find(%q) = %+v, want a single match", tt.text, matches)
+			}
+			m := matches[0]
+			if m.RuneOffset != tt.runeOffset || m.RuneLength != tt.runeLength || m.UTF16Offset != tt.utf16Offset || m.Exact != tt.exact {
+				t.Errorf("Find(%q) = offset %d, length %d, utf16 offset %d, exact %t; want %d, %d, %d, %t",
+					tt.text, m.RuneOffset, m.RuneLength, m.UTF16Offset, m.Exact,
+					tt.runeOffset, tt.runeLength, tt.utf16Offset, tt.exact)
+			}
+		})
+	}
+}
+
+func TestIsAIRespondable(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"сво", false},
+		{"сво сво сво сво сво", false},
+		{"что вы думаете про сво в последнее время", true},
+		{"сво зов сво зов и еще немного слов тут", false},
+		{"короткое сво сообщение", false},
+	}
+
+	d := New(Config{})
+	for _, tt := range tests {
+		if got := d.IsAIRespondable(tt.text); got != tt.want {
+			t.Errorf("IsAIRespondable(%q) = %t, want %t", tt.text, got, tt.want)
+		}
+	}
+}
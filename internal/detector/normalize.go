@@ -0,0 +1,162 @@
+package detector
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// homoglyphs maps lowercase Latin, Greek and digit lookalikes of Cyrillic letters used in triggers.
+var homoglyphs = map[rune]rune{
+	// Latin
+	'a': 'а',
+	'b': 'в',
+	'c': 'с',
+	'e': 'е',
+	'k': 'к',
+	'm': 'м',
+	'o': 'о',
+	'p': 'р',
+	's': 'с',
+	'v': 'в',
+	'x': 'х',
+	'y': 'у',
+	'z': 'з',
+
+	// Greek
+	'ο': 'о',
+	'β': 'в',
+	'ν': 'в',
+	'σ': 'с',
+	'ϲ': 'с',
+
+	// Cyrillic lookalikes
+	'ѕ': 'с',
+	'ё': 'е',
+	'ԁ': 'д',
+
+	// Digits
+	'0': 'о',
+	'3': 'з',
+	'8': 'в',
+}
+
+// isIgnorable reports whether r can be dropped from inside a word without breaking it.
+// Such characters are used to obfuscate triggers, e.g. "С🇷В🇷О", "С.В.О" or "С​ВО".
+func isIgnorable(r rune) bool {
+	switch r {
+	case '.', '-', '_', '*', '·', '\u00ad':
+		return true
+	}
+	return unicode.In(r, unicode.Cf, unicode.Mn, unicode.Me, unicode.Sk, unicode.So, unicode.Variation_Selector)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// foldRune maps r to its canonical lowercase Cyrillic form.
+func foldRune(r rune) rune {
+	// Fullwidth forms of ASCII letters and digits
+	if r >= '！' && r <= '～' {
+		r = r - '！' + '!'
+	}
+	r = unicode.ToLower(r)
+	if folded, ok := homoglyphs[r]; ok {
+		return folded
+	}
+	return r
+}
+
+// letter is a normalized rune together with the span of the original text it was made from.
+// Repeated letters collapse into a single letter spanning all of them.
+type letter struct {
+	r     rune
+	start int
+	end   int
+}
+
+type word struct {
+	letters []letter
+	// hasLetters is false for words consisting of digits only, which are never triggers.
+	hasLetters bool
+}
+
+func (w word) String() string {
+	runes := make([]rune, 0, len(w.letters))
+	for _, l := range w.letters {
+		runes = append(runes, l.r)
+	}
+	return string(runes)
+}
+
+// splitWords splits text into normalized words, keeping byte offsets into the original text.
+func splitWords(text string) (words []word) {
+	var (
+		current word
+		// segment is the number of letters since the start of the word or the last dot.
+		segment int
+		dot     bool
+		lower   bool
+	)
+	flush := func() {
+		if len(current.letters) > 0 {
+			words = append(words, current)
+		}
+		current = word{}
+		segment = 0
+		dot = false
+	}
+
+	for i, r := range text {
+		size := utf8.RuneLen(r)
+		if size < 0 {
+			size = 1
+		}
+
+		switch {
+		case isWordRune(r):
+			// Dots separate single letters in obfuscated triggers like "С.В.О", but end
+			// sentences written without a space, like "это сво.Дальше".
+			if dot && (segment > 1 || lower && unicode.IsUpper(r)) {
+				flush()
+			}
+			if dot {
+				segment = 0
+				dot = false
+			}
+			if unicode.IsLetter(r) {
+				current.hasLetters = true
+			}
+			lower = unicode.IsLower(r)
+
+			folded := foldRune(r)
+			if n := len(current.letters); n > 0 && current.letters[n-1].r == folded {
+				current.letters[n-1].end = i + size
+				continue
+			}
+			current.letters = append(current.letters, letter{r: folded, start: i, end: i + size})
+			segment++
+
+		case r == '.':
+			dot = true
+
+		case isIgnorable(r):
+			continue
+
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return words
+}
+
+// normalizeWord returns the normalized form of a single word, used for whitelist entries.
+func normalizeWord(s string) string {
+	words := splitWords(s)
+	if len(words) == 0 {
+		return ""
+	}
+	return words[0].String()
+}
@@ -0,0 +1,386 @@
+// Package strategy chooses how the bot responds to a trigger based on
+// configured weights and conditions.
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/detector"
+)
+
+type Kind string
+
+const (
+	// KindGoal replies with a "ГОООЛ" of random length.
+	KindGoal         Kind = "goal"
+	KindLikvidirovan Kind = "likvidirovan"
+	KindText         Kind = "text"
+	KindSticker      Kind = "sticker"
+	KindAI           Kind = "ai"
+	// KindReaction puts an emoji reaction on the triggering message instead of replying.
+	KindReaction Kind = "reaction"
+
+	KindAnimation Kind = "animation"
+	KindVoice     Kind = "voice"
+	KindAudio     Kind = "audio"
+	KindPhoto     Kind = "photo"
+)
+
+// MediaKinds are sent from the media library.
+var MediaKinds = []Kind{KindAnimation, KindVoice, KindAudio, KindPhoto}
+
+var kinds = append([]Kind{KindGoal, KindLikvidirovan, KindText, KindSticker, KindAI, KindReaction}, MediaKinds...)
+
+// DefaultReactions are used by reaction options without configured reactions
+// and as a fallback when a chat is rate-limited.
+var DefaultReactions = []Reaction{
+	{Emoji: "🔥"},
+	{Emoji: "🫡"},
+	{Emoji: "🏆"},
+}
+
+// DefaultOptions are used when no options are configured for a trigger.
+var DefaultOptions = []Option{
+	{Kind: KindLikvidirovan, Weight: 1},
+	{Kind: KindSticker, Weight: 19},
+	{Kind: KindGoal, Weight: 40},
+	{Kind: KindAI, Weight: 40},
+}
+
+type Option struct {
+	Kind       Kind       `yaml:"kind"`
+	Weight     int        `yaml:"weight"`
+	Text       string     `yaml:"text"`
+	Reactions  []Reaction `yaml:"reactions"`
+	Conditions Conditions `yaml:"when"`
+}
+
+// Reaction is either a regular emoji from the list allowed by Telegram or a custom emoji.
+// Custom emoji reactions only work in chats where administrators allowed them.
+type Reaction struct {
+	Emoji         string `yaml:"emoji"`
+	CustomEmojiID string `yaml:"custom_emoji_id"`
+}
+
+// Conditions restrict when an option can be chosen. Zero values impose no restriction.
+type Conditions struct {
+	// FromHour and ToHour limit the option to [from_hour, to_hour) in the configured time zone.
+	// The interval wraps around midnight if from_hour is greater than to_hour.
+	FromHour *int `yaml:"from_hour"`
+	ToHour   *int `yaml:"to_hour"`
+
+	// MinTriggerCount and MaxTriggerCount limit the option by the number of triggers
+	// the user has already sent in the chat.
+	MinTriggerCount int `yaml:"min_trigger_count"`
+	MaxTriggerCount int `yaml:"max_trigger_count"`
+
+	// MinMessageLength and MaxMessageLength limit the option by message length in runes.
+	MinMessageLength int `yaml:"min_message_length"`
+	MaxMessageLength int `yaml:"max_message_length"`
+}
+
+type Rules struct {
+	Default  []Option                   `yaml:"default"`
+	Triggers map[detector.Type][]Option `yaml:"triggers"`
+}
+
+type Config struct {
+	TimeZone string `yaml:"time_zone"`
+	// FallbackReactions are put on triggering messages while a chat is rate-limited.
+	FallbackReactions []Reaction `yaml:"fallback_reactions"`
+	Rules             `yaml:",inline"`
+	// Chats override rules for specific chats.
+	Chats map[int64]Rules `yaml:"chats"`
+}
+
+// Input describes the trigger a response is chosen for.
+type Input struct {
+	TriggerType   detector.Type
+	ChatID        int64
+	Time          time.Time
+	MessageLength int
+	// UserTriggerCount is only called if some option depends on it.
+	UserTriggerCount func() (int, error)
+}
+
+type Engine struct {
+	config   Config
+	location *time.Location
+}
+
+func New(config Config) (*Engine, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	location := time.UTC
+	if config.TimeZone != "" {
+		var err error
+		location, err = time.LoadLocation(config.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("load time zone: %w", err)
+		}
+	}
+
+	return &Engine{
+		config:   config,
+		location: location,
+	}, nil
+}
+
+// Options returns options configured for the input, picking the most specific rules:
+// chat and trigger type, chat, trigger type, defaults, and finally DefaultOptions.
+func (e *Engine) Options(triggerType detector.Type, chatID int64) []Option {
+	if chat, ok := e.config.Chats[chatID]; ok {
+		if options := chat.Triggers[triggerType]; len(options) > 0 {
+			return options
+		}
+		if len(chat.Default) > 0 {
+			return chat.Default
+		}
+	}
+	if options := e.config.Triggers[triggerType]; len(options) > 0 {
+		return options
+	}
+	if len(e.config.Default) > 0 {
+		return e.config.Default
+	}
+	return DefaultOptions
+}
+
+// FallbackReactions returns reactions to use while a chat is rate-limited.
+func (e *Engine) FallbackReactions() []Reaction {
+	if len(e.config.FallbackReactions) > 0 {
+		return e.config.FallbackReactions
+	}
+	return DefaultReactions
+}
+
+// Choose picks a weighted random option among the ones whose conditions are met.
+// It returns false if no option is eligible.
+func (e *Engine) Choose(rng *rand.Rand, input Input) (Option, bool, error) {
+	options := e.Options(input.TriggerType, input.ChatID)
+
+	triggerCount := -1
+	eligible := make([]Option, 0, len(options))
+	totalWeight := 0
+	for _, option := range options {
+		if option.Weight == 0 {
+			continue
+		}
+
+		if option.Conditions.dependsOnTriggerCount() && triggerCount < 0 {
+			count, err := input.UserTriggerCount()
+			if err != nil {
+				return Option{}, false, fmt.Errorf("get user trigger count: %w", err)
+			}
+			triggerCount = count
+		}
+
+		if !option.Conditions.met(input, input.Time.In(e.location), triggerCount) {
+			continue
+		}
+		eligible = append(eligible, option)
+		totalWeight += option.Weight
+	}
+
+	if totalWeight == 0 {
+		return Option{}, false, nil
+	}
+
+	n := rng.IntN(totalWeight)
+	for _, option := range eligible {
+		if n < option.Weight {
+			return option, true, nil
+		}
+		n -= option.Weight
+	}
+	panic("unreachable")
+}
+
+func (c Conditions) dependsOnTriggerCount() bool {
+	return c.MinTriggerCount > 0 || c.MaxTriggerCount > 0
+}
+
+func (c Conditions) met(input Input, localTime time.Time, triggerCount int) bool {
+	if c.FromHour != nil || c.ToHour != nil {
+		from, to := 0, 24
+		if c.FromHour != nil {
+			from = *c.FromHour
+		}
+		if c.ToHour != nil {
+			to = *c.ToHour
+		}
+
+		hour := localTime.Hour()
+		if from <= to && (hour < from || hour >= to) {
+			return false
+		}
+		if from > to && hour < from && hour >= to {
+			return false
+		}
+	}
+
+	if c.MinTriggerCount > 0 && triggerCount < c.MinTriggerCount {
+		return false
+	}
+	if c.MaxTriggerCount > 0 && triggerCount > c.MaxTriggerCount {
+		return false
+	}
+
+	if c.MinMessageLength > 0 && input.MessageLength < c.MinMessageLength {
+		return false
+	}
+	if c.MaxMessageLength > 0 && input.MessageLength > c.MaxMessageLength {
+		return false
+	}
+
+	return true
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.TimeZone != "" {
+		if _, err := time.LoadLocation(c.TimeZone); err != nil {
+			errs = append(errs, fmt.Errorf("time_zone: %w", err))
+		}
+	}
+
+	errs = append(errs, validateReactions("fallback_reactions", c.FallbackReactions)...)
+	errs = append(errs, c.Rules.validate("")...)
+	for chatID, rules := range c.Chats {
+		errs = append(errs, rules.validate(fmt.Sprintf("chats.%d.", chatID))...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// UsesKind reports whether any configured option has the kind.
+func (c *Config) UsesKind(kind Kind) bool {
+	uses := func(rules Rules) bool {
+		for _, options := range rules.Triggers {
+			for _, option := range options {
+				if option.Kind == kind && option.Weight > 0 {
+					return true
+				}
+			}
+		}
+		for _, option := range rules.Default {
+			if option.Kind == kind && option.Weight > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	if uses(c.Rules) {
+		return true
+	}
+	for _, rules := range c.Chats {
+		if uses(rules) {
+			return true
+		}
+	}
+	return false
+}
+
+// Texts returns texts of all text options keyed by their path in config.
+func (c *Config) Texts() map[string]string {
+	texts := make(map[string]string)
+	c.Rules.collectTexts("", texts)
+	for chatID, rules := range c.Chats {
+		rules.collectTexts(fmt.Sprintf("chats.%d.", chatID), texts)
+	}
+	return texts
+}
+
+func (r *Rules) collectTexts(prefix string, texts map[string]string) {
+	collect := func(path string, options []Option) {
+		for i, option := range options {
+			if option.Kind == KindText {
+				texts[fmt.Sprintf("%s[%d].text", path, i)] = option.Text
+			}
+		}
+	}
+	collect(prefix+"default", r.Default)
+	for triggerType, options := range r.Triggers {
+		collect(fmt.Sprintf("%striggers.%s", prefix, triggerType), options)
+	}
+}
+
+func (r *Rules) validate(prefix string) (errs []error) {
+	errs = append(errs, validateOptions(prefix+"default", r.Default)...)
+	for triggerType, options := range r.Triggers {
+		if triggerType != detector.Svo && triggerType != detector.Zov {
+			errs = append(errs, fmt.Errorf("%striggers: unknown trigger type %q", prefix, triggerType))
+			continue
+		}
+		errs = append(errs, validateOptions(fmt.Sprintf("%striggers.%s", prefix, triggerType), options)...)
+	}
+	return errs
+}
+
+func validateOptions(path string, options []Option) (errs []error) {
+	totalWeight := 0
+	for i, option := range options {
+		optionPath := fmt.Sprintf("%s[%d]", path, i)
+
+		if !slices.Contains(kinds, option.Kind) {
+			errs = append(errs, fmt.Errorf("%s.kind: unknown response kind %q", optionPath, option.Kind))
+		}
+		if option.Kind == KindText && option.Text == "" {
+			errs = append(errs, fmt.Errorf("%s.text must be set for %q responses", optionPath, KindText))
+		}
+		if option.Kind != KindReaction && len(option.Reactions) > 0 {
+			errs = append(errs, fmt.Errorf("%s.reactions can only be set for %q responses", optionPath, KindReaction))
+		}
+		errs = append(errs, validateReactions(optionPath+".reactions", option.Reactions)...)
+		if option.Weight < 0 {
+			errs = append(errs, fmt.Errorf("%s.weight must not be negative, got %d", optionPath, option.Weight))
+		}
+		totalWeight += option.Weight
+
+		errs = append(errs, option.Conditions.validate(optionPath+".when")...)
+	}
+
+	if len(options) > 0 && totalWeight <= 0 {
+		errs = append(errs, fmt.Errorf("%s: total weight must be positive", path))
+	}
+	return errs
+}
+
+func validateReactions(path string, reactions []Reaction) (errs []error) {
+	for i, reaction := range reactions {
+		if (reaction.Emoji == "") == (reaction.CustomEmojiID == "") {
+			errs = append(errs, fmt.Errorf("%s[%d]: exactly one of emoji and custom_emoji_id must be set", path, i))
+		}
+	}
+	return errs
+}
+
+func (c Conditions) validate(path string) (errs []error) {
+	if c.FromHour != nil && (*c.FromHour < 0 || *c.FromHour > 23) {
+		errs = append(errs, fmt.Errorf("%s.from_hour must be between 0 and 23, got %d", path, *c.FromHour))
+	}
+	if c.ToHour != nil && (*c.ToHour < 0 || *c.ToHour > 24) {
+		errs = append(errs, fmt.Errorf("%s.to_hour must be between 0 and 24, got %d", path, *c.ToHour))
+	}
+	if c.MinTriggerCount < 0 || c.MaxTriggerCount < 0 {
+		errs = append(errs, fmt.Errorf("%s: trigger counts must not be negative", path))
+	}
+	if c.MaxTriggerCount > 0 && c.MinTriggerCount > c.MaxTriggerCount {
+		errs = append(errs, fmt.Errorf("%s.min_trigger_count must not exceed max_trigger_count", path))
+	}
+	if c.MinMessageLength < 0 || c.MaxMessageLength < 0 {
+		errs = append(errs, fmt.Errorf("%s: message lengths must not be negative", path))
+	}
+	if c.MaxMessageLength > 0 && c.MinMessageLength > c.MaxMessageLength {
+		errs = append(errs, fmt.Errorf("%s.min_message_length must not exceed max_message_length", path))
+	}
+	return errs
+}
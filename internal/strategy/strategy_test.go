@@ -0,0 +1,237 @@
+package strategy
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/LeKSuS-04/svoi-bot/internal/detector"
+)
+
+func newRand() *rand.Rand {
+	return rand.New(rand.NewPCG(1, 2))
+}
+
+func newEngine(t *testing.T, config Config) *Engine {
+	t.Helper()
+	engine, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func hours(from, to int) Conditions {
+	return Conditions{FromHour: &from, ToHour: &to}
+}
+
+func at(hour int) time.Time {
+	return time.Date(2025, 1, 1, hour, 30, 0, 0, time.UTC)
+}
+
+func TestChooseWeights(t *testing.T) {
+	engine := newEngine(t, Config{Rules: Rules{Default: []Option{
+		{Kind: KindGoal, Weight: 1},
+		{Kind: KindSticker, Weight: 3},
+		{Kind: KindAI, Weight: 0},
+	}}})
+
+	const n = 10000
+	rng := newRand()
+	counts := make(map[Kind]int)
+	for range n {
+		option, ok, err := engine.Choose(rng, Input{TriggerType: detector.Svo, Time: at(12)})
+		if err != nil || !ok {
+			t.Fatalf("Choose() = %v, %v", ok, err)
+		}
+		counts[option.Kind]++
+	}
+
+	if counts[KindAI] != 0 {
+		t.Errorf("option with zero weight was chosen %d times", counts[KindAI])
+	}
+	if share := float64(counts[KindSticker]) / n; math.Abs(share-0.75) > 0.02 {
+		t.Errorf("option with 3/4 of weight was chosen in %.3f of cases", share)
+	}
+}
+
+func TestChooseDeterministic(t *testing.T) {
+	engine := newEngine(t, Config{})
+	choose := func() (kinds []Kind) {
+		rng := newRand()
+		for range 20 {
+			option, _, err := engine.Choose(rng, Input{TriggerType: detector.Zov, Time: at(12)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			kinds = append(kinds, option.Kind)
+		}
+		return kinds
+	}
+
+	first, second := choose(), choose()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("choices with the same seed differ: %v and %v", first, second)
+		}
+	}
+}
+
+func TestChooseConditions(t *testing.T) {
+	engine := newEngine(t, Config{Rules: Rules{Default: []Option{
+		{Kind: KindGoal, Weight: 1, Conditions: Conditions{MaxMessageLength: 10}},
+		{Kind: KindAI, Weight: 1, Conditions: Conditions{MinMessageLength: 50}},
+		{Kind: KindLikvidirovan, Weight: 1, Conditions: Conditions{MinTriggerCount: 100}},
+		{Kind: KindSticker, Weight: 1, Conditions: Conditions{MinTriggerCount: 5, MaxTriggerCount: 10}},
+	}}})
+
+	tests := []struct {
+		name          string
+		messageLength int
+		triggerCount  int
+		want          []Kind
+	}{
+		{"short message", 5, 0, []Kind{KindGoal}},
+		{"long message", 60, 0, []Kind{KindAI}},
+		{"medium message", 30, 0, nil},
+		{"trigger count in range", 30, 7, []Kind{KindSticker}},
+		{"many triggers", 60, 150, []Kind{KindAI, KindLikvidirovan}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := newRand()
+			chosen := make(map[Kind]bool)
+			for range 100 {
+				option, ok, err := engine.Choose(rng, Input{
+					TriggerType:      detector.Svo,
+					Time:             at(12),
+					MessageLength:    tt.messageLength,
+					UserTriggerCount: func() (int, error) { return tt.triggerCount, nil },
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok {
+					chosen[option.Kind] = true
+				}
+			}
+
+			if len(chosen) != len(tt.want) {
+				t.Errorf("chosen kinds = %v, want %v", chosen, tt.want)
+			}
+			for _, kind := range tt.want {
+				if !chosen[kind] {
+					t.Errorf("%q was never chosen, chosen kinds = %v", kind, chosen)
+				}
+			}
+		})
+	}
+}
+
+func TestChooseTriggerCountError(t *testing.T) {
+	engine := newEngine(t, Config{Rules: Rules{Default: []Option{
+		{Kind: KindGoal, Weight: 1, Conditions: Conditions{MinTriggerCount: 1}},
+	}}})
+
+	_, _, err := engine.Choose(newRand(), Input{
+		TriggerType:      detector.Svo,
+		Time:             at(12),
+		UserTriggerCount: func() (int, error) { return 0, errors.New("db is down") },
+	})
+	if err == nil {
+		t.Error("Choose() succeeded when trigger count can't be retrieved")
+	}
+}
+
+func TestChooseHours(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions Conditions
+		eligible   []int
+		ineligible []int
+	}{
+		{"day", hours(9, 18), []int{9, 12, 17}, []int{0, 8, 18, 23}},
+		{"night wraps around midnight", hours(22, 6), []int{22, 23, 0, 5}, []int{6, 12, 21}},
+		{"until midnight", hours(18, 24), []int{18, 23}, []int{0, 17}},
+		{"from midnight", hours(0, 6), []int{0, 5}, []int{6, 23}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newEngine(t, Config{Rules: Rules{Default: []Option{
+				{Kind: KindGoal, Weight: 1, Conditions: tt.conditions},
+			}}})
+			choose := func(hour int) bool {
+				_, ok, err := engine.Choose(newRand(), Input{TriggerType: detector.Svo, Time: at(hour)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ok
+			}
+
+			for _, hour := range tt.eligible {
+				if !choose(hour) {
+					t.Errorf("option is not eligible at %d:30", hour)
+				}
+			}
+			for _, hour := range tt.ineligible {
+				if choose(hour) {
+					t.Errorf("option is eligible at %d:30", hour)
+				}
+			}
+		})
+	}
+}
+
+func TestChooseTimeZone(t *testing.T) {
+	engine := newEngine(t, Config{
+		TimeZone: "Europe/Moscow",
+		Rules:    Rules{Default: []Option{{Kind: KindGoal, Weight: 1, Conditions: hours(22, 6)}}},
+	})
+
+	// 20:30 UTC is 23:30 in Moscow.
+	if _, ok, _ := engine.Choose(newRand(), Input{TriggerType: detector.Svo, Time: at(20)}); !ok {
+		t.Error("hours are not checked in the configured time zone")
+	}
+}
+
+func TestOptionsPrecedence(t *testing.T) {
+	chatOption := []Option{{Kind: KindText, Text: "chat", Weight: 1}}
+	chatTriggerOption := []Option{{Kind: KindText, Text: "chat trigger", Weight: 1}}
+	triggerOption := []Option{{Kind: KindText, Text: "trigger", Weight: 1}}
+	defaultOption := []Option{{Kind: KindText, Text: "default", Weight: 1}}
+	engine := newEngine(t, Config{
+		Rules: Rules{
+			Default:  defaultOption,
+			Triggers: map[detector.Type][]Option{detector.Zov: triggerOption},
+		},
+		Chats: map[int64]Rules{
+			1: {Default: chatOption, Triggers: map[detector.Type][]Option{detector.Zov: chatTriggerOption}},
+			2: {Default: chatOption},
+		},
+	})
+
+	tests := []struct {
+		triggerType detector.Type
+		chatID      int64
+		want        string
+	}{
+		{detector.Zov, 1, "chat trigger"},
+		{detector.Svo, 1, "chat"},
+		{detector.Zov, 2, "chat"},
+		{detector.Zov, 3, "trigger"},
+		{detector.Svo, 3, "default"},
+	}
+	for _, tt := range tests {
+		if got := engine.Options(tt.triggerType, tt.chatID)[0].Text; got != tt.want {
+			t.Errorf("Options(%s, %d) = %q, want %q", tt.triggerType, tt.chatID, got, tt.want)
+		}
+	}
+
+	if got := newEngine(t, Config{}).Options(detector.Svo, 1); len(got) != len(DefaultOptions) {
+		t.Errorf("Options() without rules = %v, want DefaultOptions", got)
+	}
+}
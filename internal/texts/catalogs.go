@@ -0,0 +1,215 @@
+package texts
+
+// Catalogs are built-in phrases of every supported language. Configured phrases replace them
+// message by message.
+var Catalogs = map[Language]Catalog{
+	Russian: {
+		Goal:         {`Г{{ repeat "О" (randInt 3 13) }}Л`},
+		Likvidirovan: {"ЛИКВИДИРОВАН"},
+		Spam:         {"Спамер"},
+		SpamMuted:    {`{{ mention .User }}, не буду отвечать вам {{ .Minutes }} {{ plural .Minutes "минуту" "минуты" "минут" }}`},
+		StatsLine: {
+			`{{ .Name }}: {{ .Svo }} СВО и {{ .Zov }} {{ plural .Zov "ЗОВ" "ЗОВ-а" "ЗОВ-ов" }} ` +
+				`повлекли за собой {{ .Likvidirovan }} {{ plural .Likvidirovan "ЛИКВИДАЦИЮ" "ЛИКВИДАЦИИ" "ЛИКВИДАЦИЙ" }}`,
+		},
+		StatsEmpty: {"Для этого чата не было собрано никакой статистики :("},
+
+		Unauthorized: {"У вас нет прав на эту команду"},
+		Pwd:          {"chat_id: {{ .ChatID }}{{ if .IsAdmin }}\nis_admin: true{{ end }}"},
+
+		BroadcastNoReply: {"Ответьте на сообщение, чтобы разослать его"},
+		BroadcastNoText:  {"Ответьте на сообщение с текстом, чтобы разослать его"},
+		BroadcastResult: {
+			`Рассылка по {{ .Total }} {{ plural .Total "чату" "чатам" "чатам" }} завершена: ` +
+				`{{ .Success }} успешно, {{ .Failure }} с ошибкой`,
+		},
+
+		ExportUsage:         {"Использование: /exportstats [csv|json] [chat_id]"},
+		ExportInvalidFormat: {"Неизвестный формат {{ printf \"%q\" .Format }}, поддерживаются csv и json"},
+		ExportInvalidChatID: {"Некорректный chat_id {{ printf \"%q\" .ChatID }}"},
+		ExportCaption:       {`Выгружено {{ .Count }} {{ plural .Count "запись" "записи" "записей" }}`},
+
+		BackupDisabled: {"Резервное копирование не настроено"},
+		BackupFailed:   {"Не удалось сделать резервную копию: {{ .Error }}"},
+		BackupDone:     {"Резервная копия сохранена в {{ .Path }}"},
+
+		LanguageCurrent: {"Язык чата: {{ .Language }}. Доступные языки: {{ .Languages }}. Сбросить: /language reset"},
+		LanguageSet:     {"Теперь я говорю по-русски"},
+		LanguageReset:   {"Язык чата сброшен, буду отвечать на языке собеседника"},
+		LanguageUnknown: {"Язык {{ printf \"%q\" .Language }} не поддерживается. Доступные языки: {{ .Languages }}"},
+
+		SpamSensitivityCurrent: {"Чувствительность к спаму: {{ .Sensitivity }}. Доступные значения: {{ .Sensitivities }}. Сбросить: /spam reset"},
+		SpamSensitivitySet:     {"Чувствительность к спаму изменена на {{ .Sensitivity }}"},
+		SpamSensitivityReset:   {"Чувствительность к спаму сброшена на {{ .Sensitivity }}"},
+		SpamSensitivityUnknown: {"Неизвестная чувствительность {{ printf \"%q\" .Sensitivity }}. Доступные значения: {{ .Sensitivities }}"},
+
+		PersonaCurrent: {"Персона ИИ: {{ .Persona }}. Доступные персоны: {{ .Personas }}. Сбросить: /persona reset"},
+		PersonaSet:     {"Персона ИИ изменена на {{ .Persona }}"},
+		PersonaReset:   {"Персона ИИ сброшена на {{ .Persona }}"},
+		PersonaUnknown: {"Неизвестная персона {{ printf \"%q\" .Persona }}. Доступные персоны: {{ .Personas }}"},
+		NotChatAdmin:   {"Менять настройки чата могут только его администраторы"},
+
+		AIRepliesCurrent: {"Ответы ИИ на упоминания, ответы и личные сообщения {{ if .Enabled }}включены{{ else }}выключены{{ end }}. Изменить: /aireplies on|off|reset"},
+		AIRepliesSet:     {"Ответы ИИ {{ if .Enabled }}включены{{ else }}выключены{{ end }}"},
+		AIRepliesReset:   {"Настройка ответов ИИ сброшена, они {{ if .Enabled }}включены{{ else }}выключены{{ end }}"},
+		AIRepliesUsage:   {"Использование: /aireplies on|off|reset"},
+		AIReplyFailed:    {"Не могу сейчас ответить, попробуйте позже"},
+
+		StickerSetAddUsage:    {"Использование: /addstickerset [название], или ответьте на стикер из набора"},
+		StickerSetSendSticker: {"Отправьте стикер из набора, который нужно добавить"},
+		StickerSetAdded:       {`Набор {{ .Name }} добавлен, в нём {{ .Count }} {{ plural .Count "стикер" "стикера" "стикеров" }}`},
+		StickerSetNotFound:    {"Не удалось найти набор {{ printf \"%q\" .Name }}"},
+		StickerSetNoName:      {"Этот стикер не из набора"},
+		StickerSetRemoveUsage: {"Использование: /removestickerset <название>, или ответьте на стикер из набора"},
+		StickerSetRemoved:     {"Набор {{ .Name }} удалён"},
+		StickerSetUnknown:     {"Набор {{ printf \"%q\" .Name }} не используется"},
+		StickerExcludeUsage:   {"Ответьте на стикер, чтобы больше его не отправлять"},
+		StickerExcluded:       {"Больше не буду отправлять этот стикер из набора {{ .Name }}"},
+		StickerSetsLine: {
+			`{{ .Name }}{{ if .Configured }} (из конфига){{ end }}: ` +
+				`{{ if .Loaded }}{{ .Count }} {{ plural .Count "стикер" "стикера" "стикеров" }}{{ else }}не удалось загрузить{{ end }}, ` +
+				`отправлено {{ .Usage }} {{ plural .Usage "раз" "раза" "раз" }}`,
+		},
+		StickerSetsEmpty: {"Наборов стикеров нет, добавьте их через /addstickerset"},
+	},
+
+	English: {
+		Goal:         {`G{{ repeat "O" (randInt 3 13) }}AL`},
+		Likvidirovan: {"LIQUIDATED"},
+		Spam:         {"Spammer"},
+		SpamMuted:    {`{{ mention .User }}, I will ignore you for {{ .Minutes }} {{ plural .Minutes "minute" "" "minutes" }}`},
+		StatsLine: {
+			`{{ .Name }}: {{ .Svo }} SVO and {{ .Zov }} ZOV ` +
+				`led to {{ .Likvidirovan }} {{ plural .Likvidirovan "LIQUIDATION" "" "LIQUIDATIONS" }}`,
+		},
+		StatsEmpty: {"No stats have been collected for this chat :("},
+
+		Unauthorized: {"You are not authorized to use this command"},
+		Pwd:          {"chat_id: {{ .ChatID }}{{ if .IsAdmin }}\nis_admin: true{{ end }}"},
+
+		BroadcastNoReply: {"Please reply to a message to broadcast it"},
+		BroadcastNoText:  {"Please reply to a message with text to broadcast it"},
+		BroadcastResult: {
+			`Finished broadcasting to {{ .Total }} {{ plural .Total "chat" "" "chats" }}: ` +
+				`{{ .Success }} success, {{ .Failure }} failure`,
+		},
+
+		ExportUsage:         {"Usage: /exportstats [csv|json] [chat_id]"},
+		ExportInvalidFormat: {"Unknown format {{ printf \"%q\" .Format }}, csv and json are supported"},
+		ExportInvalidChatID: {"Invalid chat id {{ printf \"%q\" .ChatID }}"},
+		ExportCaption:       {`Exported {{ .Count }} {{ plural .Count "record" "" "records" }}`},
+
+		BackupDisabled: {"Backups are not configured"},
+		BackupFailed:   {"Backup failed: {{ .Error }}"},
+		BackupDone:     {"Database backed up to {{ .Path }}"},
+
+		LanguageCurrent: {"Chat language: {{ .Language }}. Available languages: {{ .Languages }}. Reset: /language reset"},
+		LanguageSet:     {"I speak English now"},
+		LanguageReset:   {"Chat language was reset, I will reply in the language of each user"},
+		LanguageUnknown: {"Language {{ printf \"%q\" .Language }} is not supported. Available languages: {{ .Languages }}"},
+
+		SpamSensitivityCurrent: {"Spam sensitivity: {{ .Sensitivity }}. Available values: {{ .Sensitivities }}. Reset: /spam reset"},
+		SpamSensitivitySet:     {"Spam sensitivity set to {{ .Sensitivity }}"},
+		SpamSensitivityReset:   {"Spam sensitivity reset to {{ .Sensitivity }}"},
+		SpamSensitivityUnknown: {"Unknown sensitivity {{ printf \"%q\" .Sensitivity }}. Available values: {{ .Sensitivities }}"},
+
+		PersonaCurrent: {"AI persona: {{ .Persona }}. Available personas: {{ .Personas }}. Reset: /persona reset"},
+		PersonaSet:     {"AI persona set to {{ .Persona }}"},
+		PersonaReset:   {"AI persona reset to {{ .Persona }}"},
+		PersonaUnknown: {"Unknown persona {{ printf \"%q\" .Persona }}. Available personas: {{ .Personas }}"},
+		NotChatAdmin:   {"Only chat administrators can change chat settings"},
+
+		AIRepliesCurrent: {"AI replies to mentions, replies and private messages are {{ if .Enabled }}on{{ else }}off{{ end }}. Change: /aireplies on|off|reset"},
+		AIRepliesSet:     {"AI replies turned {{ if .Enabled }}on{{ else }}off{{ end }}"},
+		AIRepliesReset:   {"AI replies reset to {{ if .Enabled }}on{{ else }}off{{ end }}"},
+		AIRepliesUsage:   {"Usage: /aireplies on|off|reset"},
+		AIReplyFailed:    {"Can't answer right now, try again later"},
+
+		StickerSetAddUsage:    {"Usage: /addstickerset [name], or reply to a sticker from the set"},
+		StickerSetSendSticker: {"Send a sticker from the set you want to add"},
+		StickerSetAdded:       {`Added set {{ .Name }} with {{ .Count }} {{ plural .Count "sticker" "" "stickers" }}`},
+		StickerSetNotFound:    {"Could not find set {{ printf \"%q\" .Name }}"},
+		StickerSetNoName:      {"This sticker does not belong to a set"},
+		StickerSetRemoveUsage: {"Usage: /removestickerset <name>, or reply to a sticker from the set"},
+		StickerSetRemoved:     {"Removed set {{ .Name }}"},
+		StickerSetUnknown:     {"Set {{ printf \"%q\" .Name }} is not used"},
+		StickerExcludeUsage:   {"Reply to a sticker to stop sending it"},
+		StickerExcluded:       {"I will not send this sticker from set {{ .Name }} anymore"},
+		StickerSetsLine: {
+			`{{ .Name }}{{ if .Configured }} (config){{ end }}: ` +
+				`{{ if .Loaded }}{{ .Count }} {{ plural .Count "sticker" "" "stickers" }}{{ else }}failed to load{{ end }}, ` +
+				`sent {{ .Usage }} {{ plural .Usage "time" "" "times" }}`,
+		},
+		StickerSetsEmpty: {"There are no sticker sets, add them with /addstickerset"},
+	},
+
+	Ukrainian: {
+		Goal:         {`Г{{ repeat "О" (randInt 3 13) }}Л`},
+		Likvidirovan: {"ЛІКВІДОВАНО"},
+		Spam:         {"Спамер"},
+		SpamMuted:    {`{{ mention .User }}, не відповідатиму вам {{ .Minutes }} {{ plural .Minutes "хвилину" "хвилини" "хвилин" }}`},
+		StatsLine: {
+			`{{ .Name }}: {{ .Svo }} СВО і {{ .Zov }} {{ plural .Zov "ЗОВ" "ЗОВ-и" "ЗОВ-ів" }} ` +
+				`спричинили {{ .Likvidirovan }} {{ plural .Likvidirovan "ЛІКВІДАЦІЮ" "ЛІКВІДАЦІЇ" "ЛІКВІДАЦІЙ" }}`,
+		},
+		StatsEmpty: {"Для цього чату не було зібрано жодної статистики :("},
+
+		Unauthorized: {"У вас немає прав на цю команду"},
+		Pwd:          {"chat_id: {{ .ChatID }}{{ if .IsAdmin }}\nis_admin: true{{ end }}"},
+
+		BroadcastNoReply: {"Дайте відповідь на повідомлення, щоб розіслати його"},
+		BroadcastNoText:  {"Дайте відповідь на повідомлення з текстом, щоб розіслати його"},
+		BroadcastResult: {
+			`Розсилку по {{ .Total }} {{ plural .Total "чату" "чатах" "чатах" }} завершено: ` +
+				`{{ .Success }} успішно, {{ .Failure }} з помилкою`,
+		},
+
+		ExportUsage:         {"Використання: /exportstats [csv|json] [chat_id]"},
+		ExportInvalidFormat: {"Невідомий формат {{ printf \"%q\" .Format }}, підтримуються csv і json"},
+		ExportInvalidChatID: {"Некоректний chat_id {{ printf \"%q\" .ChatID }}"},
+		ExportCaption:       {`Вивантажено {{ .Count }} {{ plural .Count "запис" "записи" "записів" }}`},
+
+		BackupDisabled: {"Резервне копіювання не налаштовано"},
+		BackupFailed:   {"Не вдалося зробити резервну копію: {{ .Error }}"},
+		BackupDone:     {"Резервну копію збережено в {{ .Path }}"},
+
+		LanguageCurrent: {"Мова чату: {{ .Language }}. Доступні мови: {{ .Languages }}. Скинути: /language reset"},
+		LanguageSet:     {"Тепер я розмовляю українською"},
+		LanguageReset:   {"Мову чату скинуто, відповідатиму мовою співрозмовника"},
+		LanguageUnknown: {"Мова {{ printf \"%q\" .Language }} не підтримується. Доступні мови: {{ .Languages }}"},
+
+		SpamSensitivityCurrent: {"Чутливість до спаму: {{ .Sensitivity }}. Доступні значення: {{ .Sensitivities }}. Скинути: /spam reset"},
+		SpamSensitivitySet:     {"Чутливість до спаму змінено на {{ .Sensitivity }}"},
+		SpamSensitivityReset:   {"Чутливість до спаму скинуто на {{ .Sensitivity }}"},
+		SpamSensitivityUnknown: {"Невідома чутливість {{ printf \"%q\" .Sensitivity }}. Доступні значення: {{ .Sensitivities }}"},
+
+		PersonaCurrent: {"Персона ШІ: {{ .Persona }}. Доступні персони: {{ .Personas }}. Скинути: /persona reset"},
+		PersonaSet:     {"Персону ШІ змінено на {{ .Persona }}"},
+		PersonaReset:   {"Персону ШІ скинуто на {{ .Persona }}"},
+		PersonaUnknown: {"Невідома персона {{ printf \"%q\" .Persona }}. Доступні персони: {{ .Personas }}"},
+		NotChatAdmin:   {"Змінювати налаштування чату можуть лише його адміністратори"},
+
+		AIRepliesCurrent: {"Відповіді ШІ на згадки, відповіді та особисті повідомлення {{ if .Enabled }}увімкнено{{ else }}вимкнено{{ end }}. Змінити: /aireplies on|off|reset"},
+		AIRepliesSet:     {"Відповіді ШІ {{ if .Enabled }}увімкнено{{ else }}вимкнено{{ end }}"},
+		AIRepliesReset:   {"Налаштування відповідей ШІ скинуто, їх {{ if .Enabled }}увімкнено{{ else }}вимкнено{{ end }}"},
+		AIRepliesUsage:   {"Використання: /aireplies on|off|reset"},
+		AIReplyFailed:    {"Не можу зараз відповісти, спробуйте пізніше"},
+
+		StickerSetAddUsage:    {"Використання: /addstickerset [назва], або дайте відповідь на стікер із набору"},
+		StickerSetSendSticker: {"Надішліть стікер із набору, який потрібно додати"},
+		StickerSetAdded:       {`Набір {{ .Name }} додано, у ньому {{ .Count }} {{ plural .Count "стікер" "стікери" "стікерів" }}`},
+		StickerSetNotFound:    {"Не вдалося знайти набір {{ printf \"%q\" .Name }}"},
+		StickerSetNoName:      {"Цей стікер не з набору"},
+		StickerSetRemoveUsage: {"Використання: /removestickerset <назва>, або дайте відповідь на стікер із набору"},
+		StickerSetRemoved:     {"Набір {{ .Name }} видалено"},
+		StickerSetUnknown:     {"Набір {{ printf \"%q\" .Name }} не використовується"},
+		StickerExcludeUsage:   {"Дайте відповідь на стікер, щоб більше його не надсилати"},
+		StickerExcluded:       {"Більше не надсилатиму цей стікер із набору {{ .Name }}"},
+		StickerSetsLine: {
+			`{{ .Name }}{{ if .Configured }} (з конфігу){{ end }}: ` +
+				`{{ if .Loaded }}{{ .Count }} {{ plural .Count "стікер" "стікери" "стікерів" }}{{ else }}не вдалося завантажити{{ end }}, ` +
+				`надіслано {{ .Usage }} {{ plural .Usage "раз" "рази" "разів" }}`,
+		},
+		StickerSetsEmpty: {"Наборів стікерів немає, додайте їх через /addstickerset"},
+	},
+}
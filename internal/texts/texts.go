@@ -0,0 +1,354 @@
+// Package texts renders bot messages from text/template phrases, so that operators
+// can change and extend them in config without touching the code. Phrases are kept
+// in catalogs, one per supported language.
+package texts
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+type Name string
+
+const (
+	// Goal is the default reply to a trigger.
+	Goal         Name = "goal"
+	Likvidirovan Name = "likvidirovan"
+	// Spam is a warning sent to a user once per spam window, rendered with Trigger.
+	Spam Name = "spam"
+	// SpamMuted is sent when a user is muted for spam.
+	SpamMuted Name = "spam_muted"
+	// StatsLine is rendered with Stats for every user in /svoistats.
+	StatsLine  Name = "stats_line"
+	StatsEmpty Name = "stats_empty"
+
+	Unauthorized Name = "unauthorized"
+	Pwd          Name = "pwd"
+
+	BroadcastNoReply Name = "broadcast_no_reply"
+	BroadcastNoText  Name = "broadcast_no_text"
+	BroadcastResult  Name = "broadcast_result"
+
+	ExportUsage         Name = "export_usage"
+	ExportInvalidFormat Name = "export_invalid_format"
+	ExportInvalidChatID Name = "export_invalid_chat_id"
+	ExportCaption       Name = "export_caption"
+
+	BackupDisabled Name = "backup_disabled"
+	BackupFailed   Name = "backup_failed"
+	BackupDone     Name = "backup_done"
+
+	LanguageCurrent Name = "language_current"
+	LanguageSet     Name = "language_set"
+	LanguageReset   Name = "language_reset"
+	LanguageUnknown Name = "language_unknown"
+	// NotChatAdmin is sent when a regular member tries to change chat settings.
+	NotChatAdmin Name = "not_chat_admin"
+
+	SpamSensitivityCurrent Name = "spam_sensitivity_current"
+	SpamSensitivitySet     Name = "spam_sensitivity_set"
+	SpamSensitivityReset   Name = "spam_sensitivity_reset"
+	SpamSensitivityUnknown Name = "spam_sensitivity_unknown"
+
+	PersonaCurrent Name = "persona_current"
+	PersonaSet     Name = "persona_set"
+	PersonaReset   Name = "persona_reset"
+	PersonaUnknown Name = "persona_unknown"
+
+	AIRepliesCurrent Name = "ai_replies_current"
+	AIRepliesSet     Name = "ai_replies_set"
+	AIRepliesReset   Name = "ai_replies_reset"
+	AIRepliesUsage   Name = "ai_replies_usage"
+	// AIReplyFailed is sent instead of AI replies to messages addressed to the bot that
+	// could not be generated.
+	AIReplyFailed Name = "ai_reply_failed"
+
+	StickerSetAddUsage    Name = "sticker_set_add_usage"
+	StickerSetSendSticker Name = "sticker_set_send_sticker"
+	StickerSetAdded       Name = "sticker_set_added"
+	StickerSetNotFound    Name = "sticker_set_not_found"
+	StickerSetNoName      Name = "sticker_set_no_name"
+	StickerSetRemoveUsage Name = "sticker_set_remove_usage"
+	StickerSetRemoved     Name = "sticker_set_removed"
+	StickerSetUnknown     Name = "sticker_set_unknown"
+	StickerExcludeUsage   Name = "sticker_exclude_usage"
+	StickerExcluded       Name = "sticker_excluded"
+	// StickerSetsLine is rendered for every sticker set in /stickersets.
+	StickerSetsLine  Name = "sticker_sets_line"
+	StickerSetsEmpty Name = "sticker_sets_empty"
+)
+
+type Language string
+
+const (
+	Russian   Language = "ru"
+	English   Language = "en"
+	Ukrainian Language = "uk"
+
+	DefaultLanguage = Russian
+)
+
+// Languages are the languages with built-in catalogs.
+var Languages = []Language{Russian, English, Ukrainian}
+
+// ParseLanguage accepts language codes like "en" as well as IETF language tags like "en-US",
+// which Telegram sends as user's language_code.
+func ParseLanguage(code string) (Language, bool) {
+	code, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(code)), "-")
+	lang := Language(code)
+	return lang, slices.Contains(Languages, lang)
+}
+
+// Catalog maps message names to phrase variants. One variant is picked at random every time
+// a message is rendered.
+type Catalog map[Name][]string
+
+type Config struct {
+	DefaultLanguage Language `yaml:"default_language"`
+	// Catalogs override built-in phrases, keyed by language.
+	Catalogs map[Language]Catalog `yaml:"catalogs"`
+}
+
+// User is the author of the message the bot responds to.
+type User struct {
+	ID        int64
+	Username  string
+	FirstName string
+	LastName  string
+}
+
+// Trigger is passed to trigger responses and text options of the response strategy.
+type Trigger struct {
+	User User
+	// Quote is the part of the message that triggered the response.
+	Quote string
+	Type  string
+}
+
+// Stats is passed to StatsLine.
+type Stats struct {
+	Name         string
+	Svo          int
+	Zov          int
+	Likvidirovan int
+}
+
+func funcs(lang Language) template.FuncMap {
+	plural := Plural
+	if lang == English {
+		plural = func(n int, one, _, many string) string {
+			if n == 1 || n == -1 {
+				return one
+			}
+			return many
+		}
+	}
+
+	return template.FuncMap{
+		"plural":  plural,
+		"mention": Mention,
+		"repeat":  strings.Repeat,
+		// Random functions are bound to the rng passed to Render when a phrase is executed.
+		"choice":  func(...string) string { panic("choice is not bound to rng") },
+		"randInt": func(int, int) int { panic("randInt is not bound to rng") },
+	}
+}
+
+func randFuncs(rng *rand.Rand) template.FuncMap {
+	return template.FuncMap{
+		"choice": func(options ...string) string {
+			if len(options) == 0 {
+				return ""
+			}
+			return options[rng.IntN(len(options))]
+		},
+		"randInt": func(min, max int) int {
+			if max <= min {
+				return min
+			}
+			return min + rng.IntN(max-min)
+		},
+	}
+}
+
+// Plural picks a plural form for n by the rules of Russian and Ukrainian: one for 1, 21, 101;
+// few for 2-4, 22-24; many for 0, 5-20, 25-30 and so on. In English catalogs the few form is ignored.
+func Plural(n int, one, few, many string) string {
+	if n < 0 {
+		n = -n
+	}
+	switch {
+	case n%100 >= 11 && n%100 <= 14:
+		return many
+	case n%10 == 1:
+		return one
+	case n%10 >= 2 && n%10 <= 4:
+		return few
+	default:
+		return many
+	}
+}
+
+// Mention returns @username if the user has one, and the displayed name otherwise.
+func Mention(user User) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// Parse parses a single phrase of the language.
+func Parse(lang Language, text string) (*template.Template, error) {
+	return template.New("").Funcs(funcs(lang)).Option("missingkey=error").Parse(text)
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.DefaultLanguage != "" && !slices.Contains(Languages, c.DefaultLanguage) {
+		errs = append(errs, fmt.Errorf("default_language: unsupported language %q", c.DefaultLanguage))
+	}
+
+	for lang, catalog := range c.Catalogs {
+		if !slices.Contains(Languages, lang) {
+			errs = append(errs, fmt.Errorf("catalogs.%s: unsupported language", lang))
+			continue
+		}
+		for name, phrases := range catalog {
+			if _, ok := Catalogs[DefaultLanguage][name]; !ok {
+				errs = append(errs, fmt.Errorf("catalogs.%s.%s: unknown message", lang, name))
+				continue
+			}
+			if len(phrases) == 0 {
+				errs = append(errs, fmt.Errorf("catalogs.%s.%s must contain at least one phrase", lang, name))
+			}
+			for i, phrase := range phrases {
+				if _, err := Parse(lang, phrase); err != nil {
+					errs = append(errs, fmt.Errorf("catalogs.%s.%s[%d]: %w", lang, name, i, err))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// defaultLibrary renders built-in catalogs when a configured phrase fails.
+var defaultLibrary = func() *Library {
+	l, err := New(Config{})
+	if err != nil {
+		panic(fmt.Sprintf("parse built-in catalogs: %s", err))
+	}
+	return l
+}()
+
+// RenderDefault renders a random built-in phrase of the message.
+func RenderDefault(rng *rand.Rand, lang Language, name Name, data any) (string, error) {
+	return defaultLibrary.Render(rng, lang, name, data)
+}
+
+type inlineKey struct {
+	lang Language
+	text string
+}
+
+type Library struct {
+	defaultLanguage Language
+	phrases         map[Language]map[Name][]*template.Template
+
+	// inline caches phrases that come from elsewhere in config, such as text responses.
+	inlineMu sync.Mutex
+	inline   map[inlineKey]*template.Template
+}
+
+func New(config Config) (*Library, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	l := &Library{
+		defaultLanguage: config.DefaultLanguage,
+		phrases:         make(map[Language]map[Name][]*template.Template, len(Languages)),
+		inline:          make(map[inlineKey]*template.Template),
+	}
+	if l.defaultLanguage == "" {
+		l.defaultLanguage = DefaultLanguage
+	}
+
+	for _, lang := range Languages {
+		l.phrases[lang] = make(map[Name][]*template.Template, len(Catalogs[lang]))
+		for name, builtin := range Catalogs[lang] {
+			phrases := builtin
+			if configured := config.Catalogs[lang][name]; len(configured) > 0 {
+				phrases = configured
+			}
+			for _, phrase := range phrases {
+				tmpl, err := Parse(lang, phrase)
+				if err != nil {
+					return nil, fmt.Errorf("parse %s.%s: %w", lang, name, err)
+				}
+				l.phrases[lang][name] = append(l.phrases[lang][name], tmpl)
+			}
+		}
+	}
+	return l, nil
+}
+
+// DefaultLanguage is used when neither the chat nor the user have a supported language.
+func (l *Library) DefaultLanguage() Language {
+	return l.defaultLanguage
+}
+
+// Render renders a random phrase of the message, falling back to the default language
+// if the language is not supported. The phrase and its random functions use rng, so that
+// the output can be reproduced from its seed.
+func (l *Library) Render(rng *rand.Rand, lang Language, name Name, data any) (string, error) {
+	phrases := l.phrases[lang][name]
+	if len(phrases) == 0 {
+		phrases = l.phrases[l.defaultLanguage][name]
+	}
+	if len(phrases) == 0 {
+		return "", fmt.Errorf("unknown message %q", name)
+	}
+	return execute(phrases[rng.IntN(len(phrases))], rng, data)
+}
+
+// RenderText renders a phrase that is not part of the library.
+func (l *Library) RenderText(rng *rand.Rand, lang Language, text string, data any) (string, error) {
+	key := inlineKey{lang: lang, text: text}
+
+	l.inlineMu.Lock()
+	tmpl, ok := l.inline[key]
+	if !ok {
+		var err error
+		tmpl, err = Parse(lang, text)
+		if err != nil {
+			l.inlineMu.Unlock()
+			return "", fmt.Errorf("parse: %w", err)
+		}
+		l.inline[key] = tmpl
+	}
+	l.inlineMu.Unlock()
+
+	return execute(tmpl, rng, data)
+}
+
+// execute binds random functions of a copy of the shared template to rng and executes it.
+func execute(tmpl *template.Template, rng *rand.Rand, data any) (string, error) {
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return "", fmt.Errorf("clone: %w", err)
+	}
+	tmpl.Funcs(randFuncs(rng))
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("execute: %w", err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,53 @@
+package texts
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestRenderSeeded(t *testing.T) {
+	library, err := New(Config{Catalogs: map[Language]Catalog{
+		Russian: {Goal: {
+			`{{ choice "а" "б" "в" }}{{ randInt 1 100 }}`,
+			`{{ repeat "О" (randInt 3 10) }}`,
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func() (texts []string) {
+		rng := rand.New(rand.NewPCG(1, 2))
+		for range 20 {
+			text, err := library.Render(rng, Russian, Goal, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			texts = append(texts, text)
+		}
+		return texts
+	}
+
+	first, second := render(), render()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("renders with the same seed differ: %q and %q", first, second)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "many"}, {1, "one"}, {2, "few"}, {4, "few"}, {5, "many"},
+		{11, "many"}, {12, "many"}, {14, "many"}, {21, "one"}, {22, "few"},
+		{101, "one"}, {111, "many"}, {-1, "one"},
+	}
+	for _, tt := range tests {
+		if got := Plural(tt.n, "one", "few", "many"); got != tt.want {
+			t.Errorf("Plural(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}